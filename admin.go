@@ -0,0 +1,299 @@
+package httpcache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// NewAdminHandler returns an [http.Handler] that can be used to inspect and purge the cache of the given client.
+//
+// The handler is meant for operators and should only be made available on an internal port or behind authentication.
+// It can be mounted under a prefix using [http.StripPrefix].
+//
+// All responses are JSON encoded. The following endpoints are supported:
+//
+//   - GET /entries lists all stored responses. The list can be filtered using the "prefix" query parameter, which
+//     matches the start of the request URL.
+//   - GET /entry?key=... returns all variants stored for the given key, including the stored headers.
+//   - POST /purge?url=... removes all responses stored for the given URL.
+//   - POST /purge?prefix=... removes all responses with a request URL starting with the given prefix.
+//   - POST /purge?tag=... purges all responses with the given tag using [Client.PurgeTags]. The "mode" query parameter
+//     can be set to "soft" for a soft purge.
+//   - GET /stats returns the number of stored responses, their total size and the number of fresh and stale responses.
+//
+// Listing responses requires the store to implement [StoreLister]. Purging by URL or prefix additionally requires the
+// store to implement [StoreDeleter]. If the store does not implement the required interfaces, the handler responds
+// with 501 Not Implemented.
+func NewAdminHandler(client *Client) http.Handler {
+	h := &adminHandler{client: client}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /entries", h.entries)
+	mux.HandleFunc("GET /entry", h.entry)
+	mux.HandleFunc("POST /purge", h.purge)
+	mux.HandleFunc("GET /stats", h.stats)
+	return mux
+}
+
+type adminHandler struct {
+	client *Client
+}
+
+type adminEntry struct {
+	Key               string      `json:"key"`
+	Partition         string      `json:"partition,omitempty"`
+	Method            string      `json:"method"`
+	URL               string      `json:"url"`
+	Variant           string      `json:"variant,omitempty"`
+	StatusCode        int         `json:"status"`
+	Size              int64       `json:"size"`
+	Age               int64       `json:"age"`
+	FreshnessLifetime int64       `json:"freshnessLifetime"`
+	TTL               int64       `json:"ttl"`
+	Tags              []string    `json:"tags,omitempty"`
+	Header            http.Header `json:"header,omitempty"`
+}
+
+type adminStats struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+	Fresh   int   `json:"fresh"`
+	Stale   int   `json:"stale"`
+}
+
+var (
+	errAdminListUnsupported   = fmt.Errorf("store does not implement StoreLister: %w", errors.ErrUnsupported)
+	errAdminDeleteUnsupported = fmt.Errorf("store does not implement StoreDeleter: %w", errors.ErrUnsupported)
+	errAdminMissingKey        = errors.New("missing key")
+	errAdminMissingTarget     = errors.New("one of url, prefix or tag must be set")
+	errAdminInvalidMode       = errors.New(`mode must be "hard" or "soft"`)
+)
+
+func (h *adminHandler) entries(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+
+	entries := []adminEntry{}
+
+	err := h.each(r, func(e StoreEntry) {
+		if strings.HasPrefix(e.URL, prefix) {
+			entries = append(entries, h.entryFor(e, false))
+		}
+	})
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+
+	h.json(w, http.StatusOK, entries)
+}
+
+func (h *adminHandler) entry(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		h.error(w, errAdminMissingKey)
+		return
+	}
+
+	var entries []adminEntry
+
+	err := h.each(r, func(e StoreEntry) {
+		if e.Key == key {
+			entries = append(entries, h.entryFor(e, true))
+		}
+	})
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+
+	if entries == nil {
+		h.json(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+
+	h.json(w, http.StatusOK, entries)
+}
+
+func (h *adminHandler) purge(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var (
+		n   int
+		err error
+	)
+
+	switch {
+	case query.Has("tag"):
+		mode := PurgeHard
+
+		switch query.Get("mode") {
+		case "", "hard":
+		case "soft":
+			mode = PurgeSoft
+		default:
+			h.error(w, errAdminInvalidMode)
+			return
+		}
+
+		n, err = h.client.PurgeTags(r.Context(), mode, query["tag"]...)
+	case query.Has("url"):
+		url := query.Get("url")
+		n, err = h.delete(r, func(e StoreEntry) bool { return e.URL == url })
+	case query.Has("prefix"):
+		prefix := query.Get("prefix")
+		n, err = h.delete(r, func(e StoreEntry) bool { return strings.HasPrefix(e.URL, prefix) })
+	default:
+		err = errAdminMissingTarget
+	}
+
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+
+	h.json(w, http.StatusOK, map[string]int{"purged": n})
+}
+
+func (h *adminHandler) stats(w http.ResponseWriter, r *http.Request) {
+	var stats adminStats
+
+	err := h.each(r, func(e StoreEntry) {
+		stats.Entries++
+		stats.Bytes += e.Size
+
+		if h.freshnessLifetime(e) > e.Age {
+			stats.Fresh++
+		} else {
+			stats.Stale++
+		}
+	})
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+
+	h.json(w, http.StatusOK, stats)
+}
+
+func (h *adminHandler) each(r *http.Request, f func(e StoreEntry)) error {
+	lister, ok := h.client.Store.(StoreLister)
+	if !ok {
+		return errAdminListUnsupported
+	}
+
+	for e, err := range lister.Entries(r.Context()) {
+		if err != nil {
+			return err
+		}
+
+		f(e)
+	}
+
+	return nil
+}
+
+func (h *adminHandler) delete(r *http.Request, match func(e StoreEntry) bool) (int, error) {
+	deleter, ok := h.client.Store.(StoreDeleter)
+	if !ok {
+		return 0, errAdminDeleteUnsupported
+	}
+
+	var keys []string
+
+	err := h.each(r, func(e StoreEntry) {
+		if match(e) && !slices.Contains(keys, e.Key) {
+			keys = append(keys, e.Key)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var total int
+
+	for _, key := range keys {
+		n, err := deleter.Delete(r.Context(), key)
+		total += n
+
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if total > 0 {
+		h.client.observe(r.Context(), nopSpan{}, Event{Type: EventInvalidated, Reason: "admin-purge"})
+	}
+
+	return total, nil
+}
+
+func (h *adminHandler) freshnessLifetime(e StoreEntry) time.Duration {
+	respDirectives, _ := ParseResponseDirectives(strings.Join(e.Header["Cache-Control"], ","))
+
+	date, _ := http.ParseTime(e.Header.Get("Date"))
+
+	var expires time.Time
+	if s := e.Header.Get("Expires"); s != "" {
+		expires, _ = ParseExpires(s)
+	}
+
+	lifetime, _ := CalculateFreshnessLifetime(
+		h.client.config().Private,
+		date,
+		expires,
+		respDirectives.MaxAge,
+		respDirectives.SMaxAge)
+
+	return lifetime
+}
+
+func (h *adminHandler) entryFor(e StoreEntry, withHeader bool) adminEntry {
+	lifetime := h.freshnessLifetime(e)
+
+	entry := adminEntry{
+		Key:               e.Key,
+		Partition:         e.Partition,
+		Method:            e.Method,
+		URL:               e.URL,
+		Variant:           e.Variant,
+		StatusCode:        e.StatusCode,
+		Size:              e.Size,
+		Age:               int64(e.Age.Seconds()),
+		FreshnessLifetime: int64(lifetime.Seconds()),
+		TTL:               int64(max(lifetime-e.Age, 0).Seconds()),
+		Tags:              ParseTags(e.Header[TagsHeader]),
+	}
+
+	if withHeader {
+		entry.Header = e.Header
+	}
+
+	return entry
+}
+
+func (h *adminHandler) error(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		status = http.StatusNotImplemented
+	case errors.Is(err, errAdminMissingKey),
+		errors.Is(err, errAdminMissingTarget),
+		errors.Is(err, errAdminInvalidMode):
+		status = http.StatusBadRequest
+	}
+
+	h.json(w, status, map[string]string{"error": err.Error()})
+}
+
+func (h *adminHandler) json(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,224 @@
+package httpcache_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/nussjustin/httpcache"
+)
+
+func TestNewAdminHandler(t *testing.T) {
+	clock := httpcache.NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	client := &httpcache.Client{
+		Config:     httpcache.Config{Clock: clock},
+		Store:      &httpcache.MemoryStore{Clock: clock},
+		TagHeaders: httpcache.DefaultTagHeaders,
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				resp := newResp(
+					withRespHeader("Cache-Control", "max-age=60"),
+					withRespHeader("Surrogate-Key", "all "+req.URL.Path),
+					withRespBody(strings.NewReader("body")))
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	for _, u := range []string{"http://example.com/a", "http://example.com/b/1", "http://example.com/b/2"} {
+		if _, err := client.Do(newReq(withReqUrl(u))); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+	}
+
+	clock.Advance(20 * time.Second)
+
+	handler := httptest.NewServer(http.StripPrefix("/admin", httpcache.NewAdminHandler(client)))
+	defer handler.Close()
+
+	do := func(method, path string, wantStatus int, v any) {
+		t.Helper()
+
+		req, err := http.NewRequestWithContext(t.Context(), method, handler.URL+"/admin"+path, nil)
+		if err != nil {
+			t.Fatalf("NewRequest() error = %v", err)
+		}
+
+		resp, err := handler.Client().Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: error = %v", method, path, err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("%s %s: got status %d, want %d", method, path, resp.StatusCode, wantStatus)
+		}
+
+		if got, want := resp.Header.Get("Content-Type"), "application/json"; got != want {
+			t.Errorf("%s %s: got Content-Type %q, want %q", method, path, got, want)
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: failed to decode response: %v", method, path, err)
+		}
+	}
+
+	type entry struct {
+		Key               string      `json:"key"`
+		Method            string      `json:"method"`
+		URL               string      `json:"url"`
+		StatusCode        int         `json:"status"`
+		Size              int64       `json:"size"`
+		Age               int64       `json:"age"`
+		FreshnessLifetime int64       `json:"freshnessLifetime"`
+		TTL               int64       `json:"ttl"`
+		Tags              []string    `json:"tags"`
+		Header            http.Header `json:"header"`
+	}
+
+	var entries []entry
+	do("GET", "/entries?prefix=http://example.com/b/", http.StatusOK, &entries)
+
+	want := []entry{
+		{
+			Method:            "GET",
+			URL:               "http://example.com/b/1",
+			StatusCode:        200,
+			Size:              4,
+			Age:               20,
+			FreshnessLifetime: 60,
+			TTL:               40,
+			Tags:              []string{"all", "/b/1"},
+		},
+		{
+			Method:            "GET",
+			URL:               "http://example.com/b/2",
+			StatusCode:        200,
+			Size:              4,
+			Age:               20,
+			FreshnessLifetime: 60,
+			TTL:               40,
+			Tags:              []string{"all", "/b/2"},
+		},
+	}
+
+	if diff := cmp.Diff(want, entries, cmp.FilterPath(func(p cmp.Path) bool {
+		return p.Last().String() == ".Key"
+	}, cmp.Ignore())); diff != "" {
+		t.Errorf("GET /entries mismatch (-want +got):\n%s", diff)
+	}
+
+	var single []entry
+	do("GET", "/entry?key="+url.QueryEscape(entries[0].Key), http.StatusOK, &single)
+
+	if got, want := len(single), 1; got != want {
+		t.Fatalf("GET /entry returned %d entries, want %d", got, want)
+	}
+
+	if got, want := single[0].Header.Get("Cache-Control"), "max-age=60"; got != want {
+		t.Errorf("GET /entry returned Cache-Control %q, want %q", got, want)
+	}
+
+	var errResp map[string]string
+	do("GET", "/entry?key=unknown", http.StatusNotFound, &errResp)
+	do("GET", "/entry", http.StatusBadRequest, &errResp)
+	do("POST", "/purge", http.StatusBadRequest, &errResp)
+	do("POST", "/purge?tag=all&mode=medium", http.StatusBadRequest, &errResp)
+
+	var stats map[string]int64
+	do("GET", "/stats", http.StatusOK, &stats)
+
+	if diff := cmp.Diff(map[string]int64{"entries": 3, "bytes": 12, "fresh": 3, "stale": 0}, stats); diff != "" {
+		t.Errorf("GET /stats mismatch (-want +got):\n%s", diff)
+	}
+
+	var purged map[string]int
+	do("POST", "/purge?tag=/a&mode=soft", http.StatusOK, &purged)
+
+	if got, want := purged["purged"], 1; got != want {
+		t.Errorf("POST /purge?tag returned %d, want %d", got, want)
+	}
+
+	do("GET", "/stats", http.StatusOK, &stats)
+
+	if got, want := stats["stale"], int64(1); got != want {
+		t.Errorf("GET /stats returned %d stale entries after soft purge, want %d", got, want)
+	}
+
+	do("POST", "/purge?url=http://example.com/a", http.StatusOK, &purged)
+
+	if got, want := purged["purged"], 1; got != want {
+		t.Errorf("POST /purge?url returned %d, want %d", got, want)
+	}
+
+	do("POST", "/purge?prefix=http://example.com/b/", http.StatusOK, &purged)
+
+	if got, want := purged["purged"], 2; got != want {
+		t.Errorf("POST /purge?prefix returned %d, want %d", got, want)
+	}
+
+	do("GET", "/entries", http.StatusOK, &entries)
+
+	if len(entries) != 0 {
+		t.Errorf("GET /entries returned %d entries after purge, want 0", len(entries))
+	}
+}
+
+func TestNewAdminHandler_Unsupported(t *testing.T) {
+	client := &httpcache.Client{Store: &trackingStore{Store: httpcache.NewMemoryStore()}}
+
+	handler := httpcache.NewAdminHandler(client)
+
+	for _, target := range []string{"GET /entries", "GET /stats", "POST /purge?url=http://example.com/"} {
+		method, path, _ := strings.Cut(target, " ")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+
+		if got, want := rec.Code, http.StatusNotImplemented; got != want {
+			t.Errorf("%s: got status %d, want %d", target, got, want)
+		}
+	}
+}
+
+func TestNewAdminHandler_SetConfig(t *testing.T) {
+	client := &httpcache.Client{
+		Store: httpcache.NewMemoryStore(),
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				resp := newResp(withRespHeader("Cache-Control", "max-age=60, s-maxage=10"))
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	if _, err := client.Do(newReq()); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	client.SetConfig(httpcache.Config{Private: true})
+
+	rec := httptest.NewRecorder()
+	httpcache.NewAdminHandler(client).ServeHTTP(rec, httptest.NewRequest("GET", "/entries", nil))
+
+	var entries []struct {
+		FreshnessLifetime int64 `json:"freshnessLifetime"`
+	}
+
+	if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].FreshnessLifetime != 60 {
+		t.Errorf("got entries %+v, want a single entry with a freshness lifetime of 60 seconds", entries)
+	}
+}
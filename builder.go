@@ -0,0 +1,220 @@
+package httpcache
+
+import (
+	"math"
+	"time"
+)
+
+// RequestDirectivesBuilder can be used to build a valid Cache-Control request header.
+//
+// All methods except [RequestDirectivesBuilder.Directives] and [RequestDirectivesBuilder.Build] return the builder to
+// allow chaining calls.
+//
+// The zero value is ready to use.
+type RequestDirectivesBuilder struct {
+	d RequestDirectives
+}
+
+// MaxAge sets the max-age directive.
+func (b *RequestDirectivesBuilder) MaxAge(d time.Duration) *RequestDirectivesBuilder {
+	b.d.MaxAge = Opt[time.Duration]{Value: d, Valid: true}
+	return b
+}
+
+// MaxStale sets the max-stale directive.
+func (b *RequestDirectivesBuilder) MaxStale(d time.Duration) *RequestDirectivesBuilder {
+	b.d.MaxStale = Opt[time.Duration]{Value: d, Valid: true}
+	return b
+}
+
+// MaxStaleAny sets the max-stale directive without value, allowing stale responses of any age.
+func (b *RequestDirectivesBuilder) MaxStaleAny() *RequestDirectivesBuilder {
+	b.d.MaxStale = Opt[time.Duration]{Value: math.MaxInt64, Valid: true}
+	return b
+}
+
+// MinFresh sets the min-fresh directive.
+func (b *RequestDirectivesBuilder) MinFresh(d time.Duration) *RequestDirectivesBuilder {
+	b.d.MinFresh = Opt[time.Duration]{Value: d, Valid: true}
+	return b
+}
+
+// NoCache sets the no-cache directive.
+func (b *RequestDirectivesBuilder) NoCache() *RequestDirectivesBuilder {
+	b.d.NoCache = true
+	return b
+}
+
+// NoStore sets the no-store directive.
+func (b *RequestDirectivesBuilder) NoStore() *RequestDirectivesBuilder {
+	b.d.NoStore = true
+	return b
+}
+
+// NoTransform sets the no-transform directive.
+func (b *RequestDirectivesBuilder) NoTransform() *RequestDirectivesBuilder {
+	b.d.NoTransform = true
+	return b
+}
+
+// OnlyIfCached sets the only-if-cached directive.
+func (b *RequestDirectivesBuilder) OnlyIfCached() *RequestDirectivesBuilder {
+	b.d.OnlyIfCached = true
+	return b
+}
+
+// Extension adds an extension directive without value.
+func (b *RequestDirectivesBuilder) Extension(name string) *RequestDirectivesBuilder {
+	b.d.Extensions = append(b.d.Extensions, ExtensionDirective{Name: name})
+	return b
+}
+
+// ExtensionValue adds an extension directive with the given value.
+func (b *RequestDirectivesBuilder) ExtensionValue(name, value string) *RequestDirectivesBuilder {
+	b.d.Extensions = append(b.d.Extensions, ExtensionDirective{Name: name, Value: Opt[string]{Value: value, Valid: true}})
+	return b
+}
+
+// Directives validates and returns the built directives.
+//
+// See [RequestDirectives.Validate] for details on the validation.
+func (b *RequestDirectivesBuilder) Directives() (RequestDirectives, error) {
+	if err := b.d.Validate(); err != nil {
+		return RequestDirectives{}, err
+	}
+	return b.d, nil
+}
+
+// Build validates the built directives and returns them formatted as Cache-Control header value.
+//
+// Parsing the returned string using [ParseRequestDirectives] results in the same directives as returned by
+// [RequestDirectivesBuilder.Directives].
+func (b *RequestDirectivesBuilder) Build() (string, error) {
+	d, err := b.Directives()
+	if err != nil {
+		return "", err
+	}
+	return d.String(), nil
+}
+
+// ResponseDirectivesBuilder can be used to build a valid Cache-Control response header.
+//
+// All methods except [ResponseDirectivesBuilder.Directives] and [ResponseDirectivesBuilder.Build] return the builder
+// to allow chaining calls.
+//
+// The zero value is ready to use.
+type ResponseDirectivesBuilder struct {
+	d ResponseDirectives
+}
+
+// Immutable sets the immutable directive.
+func (b *ResponseDirectivesBuilder) Immutable() *ResponseDirectivesBuilder {
+	b.d.Immutable = true
+	return b
+}
+
+// MaxAge sets the max-age directive.
+func (b *ResponseDirectivesBuilder) MaxAge(d time.Duration) *ResponseDirectivesBuilder {
+	b.d.MaxAge = Opt[time.Duration]{Value: d, Valid: true}
+	return b
+}
+
+// MustRevalidate sets the must-revalidate directive.
+func (b *ResponseDirectivesBuilder) MustRevalidate() *ResponseDirectivesBuilder {
+	b.d.MustRevalidate = true
+	return b
+}
+
+// MustUnderstand sets the must-understand directive.
+func (b *ResponseDirectivesBuilder) MustUnderstand() *ResponseDirectivesBuilder {
+	b.d.MustUnderstand = true
+	return b
+}
+
+// NoCache sets the no-cache directive.
+//
+// If any headers are given, they are used as value for the directive. Otherwise, the directive will have no value.
+func (b *ResponseDirectivesBuilder) NoCache(headers ...string) *ResponseDirectivesBuilder {
+	b.d.NoCache = true
+	b.d.NoCacheHeaders = nil
+	if len(headers) > 0 {
+		b.d.NoCacheHeaders = append([]string{}, headers...)
+	}
+	return b
+}
+
+// NoStore sets the no-store directive.
+func (b *ResponseDirectivesBuilder) NoStore() *ResponseDirectivesBuilder {
+	b.d.NoStore = true
+	return b
+}
+
+// NoTransform sets the no-transform directive.
+func (b *ResponseDirectivesBuilder) NoTransform() *ResponseDirectivesBuilder {
+	b.d.NoTransform = true
+	return b
+}
+
+// Private sets the private directive.
+//
+// If any headers are given, they are used as value for the directive. Otherwise, the directive will have no value.
+func (b *ResponseDirectivesBuilder) Private(headers ...string) *ResponseDirectivesBuilder {
+	b.d.Private = true
+	b.d.PrivateHeaders = nil
+	if len(headers) > 0 {
+		b.d.PrivateHeaders = append([]string{}, headers...)
+	}
+	return b
+}
+
+// ProxyRevalidate sets the proxy-revalidate directive.
+func (b *ResponseDirectivesBuilder) ProxyRevalidate() *ResponseDirectivesBuilder {
+	b.d.ProxyRevalidate = true
+	return b
+}
+
+// Public sets the public directive.
+func (b *ResponseDirectivesBuilder) Public() *ResponseDirectivesBuilder {
+	b.d.Public = true
+	return b
+}
+
+// SMaxAge sets the s-maxage directive.
+func (b *ResponseDirectivesBuilder) SMaxAge(d time.Duration) *ResponseDirectivesBuilder {
+	b.d.SMaxAge = Opt[time.Duration]{Value: d, Valid: true}
+	return b
+}
+
+// Extension adds an extension directive without value.
+func (b *ResponseDirectivesBuilder) Extension(name string) *ResponseDirectivesBuilder {
+	b.d.Extensions = append(b.d.Extensions, ExtensionDirective{Name: name})
+	return b
+}
+
+// ExtensionValue adds an extension directive with the given value.
+func (b *ResponseDirectivesBuilder) ExtensionValue(name, value string) *ResponseDirectivesBuilder {
+	b.d.Extensions = append(b.d.Extensions, ExtensionDirective{Name: name, Value: Opt[string]{Value: value, Valid: true}})
+	return b
+}
+
+// Directives validates and returns the built directives.
+//
+// See [ResponseDirectives.Validate] for details on the validation.
+func (b *ResponseDirectivesBuilder) Directives() (ResponseDirectives, error) {
+	if err := b.d.Validate(); err != nil {
+		return ResponseDirectives{}, err
+	}
+	return b.d, nil
+}
+
+// Build validates the built directives and returns them formatted as Cache-Control header value.
+//
+// Parsing the returned string using [ParseResponseDirectives] results in the same directives as returned by
+// [ResponseDirectivesBuilder.Directives].
+func (b *ResponseDirectivesBuilder) Build() (string, error) {
+	d, err := b.Directives()
+	if err != nil {
+		return "", err
+	}
+	return d.String(), nil
+}
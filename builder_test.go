@@ -0,0 +1,106 @@
+package httpcache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nussjustin/httpcache"
+)
+
+func TestRequestDirectivesBuilder(t *testing.T) {
+	var b httpcache.RequestDirectivesBuilder
+
+	got, err := b.
+		MaxAge(time.Minute).
+		MaxStale(2*time.Minute).
+		MinFresh(3*time.Minute).
+		NoCache().
+		NoStore().
+		NoTransform().
+		OnlyIfCached().
+		Extension("ext").
+		ExtensionValue("ext-value", "a, b").
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if want := `max-age=60, max-stale=120, min-fresh=180, no-cache, no-store, no-transform, only-if-cached, ext, ext-value="a, b"`; got != want {
+		t.Errorf("Build() = %q, want %q", got, want)
+	}
+
+	d, err := b.Directives()
+	if err != nil {
+		t.Fatalf("Directives() error = %v", err)
+	}
+
+	parsed, err := httpcache.ParseRequestDirectives(got)
+	if err != nil {
+		t.Fatalf("ParseRequestDirectives() error = %v", err)
+	}
+
+	if diff := cmp.Diff(d, parsed); diff != "" {
+		t.Errorf("ParseRequestDirectives() mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := new(httpcache.RequestDirectivesBuilder).MaxAge(-time.Second).Build(); err == nil {
+		t.Error("Build() with negative max-age error = nil, want error")
+	}
+}
+
+func TestResponseDirectivesBuilder(t *testing.T) {
+	var b httpcache.ResponseDirectivesBuilder
+
+	got, err := b.
+		Immutable().
+		MaxAge(time.Minute).
+		MustRevalidate().
+		MustUnderstand().
+		NoCache("Set-Cookie").
+		NoStore().
+		NoTransform().
+		Private().
+		ProxyRevalidate().
+		Public().
+		SMaxAge(2*time.Minute).
+		ExtensionValue("ext", `quote"d`).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	want := `immutable, max-age=60, must-revalidate, must-understand, no-cache="Set-Cookie", no-store, no-transform, private, ` +
+		`proxy-revalidate, public, s-maxage=120, ext="quote\"d"`
+	if got != want {
+		t.Errorf("Build() = %q, want %q", got, want)
+	}
+
+	d, err := b.Directives()
+	if err != nil {
+		t.Fatalf("Directives() error = %v", err)
+	}
+
+	parsed, err := httpcache.ParseResponseDirectives(got)
+	if err != nil {
+		t.Fatalf("ParseResponseDirectives() error = %v", err)
+	}
+
+	if diff := cmp.Diff(d, parsed); diff != "" {
+		t.Errorf("ParseResponseDirectives() mismatch (-want +got):\n%s", diff)
+	}
+
+	tests := []struct {
+		name string
+		b    *httpcache.ResponseDirectivesBuilder
+	}{
+		{name: "fractional max-age", b: new(httpcache.ResponseDirectivesBuilder).MaxAge(time.Millisecond)},
+		{name: "invalid private header", b: new(httpcache.ResponseDirectivesBuilder).Private(`Bad Header`)},
+		{name: "invalid extension", b: new(httpcache.ResponseDirectivesBuilder).Extension(`bad"name`)},
+	}
+	for _, tt := range tests {
+		if _, err := tt.b.Build(); err == nil {
+			t.Errorf("%s: Build() error = nil, want error", tt.name)
+		}
+	}
+}
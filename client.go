@@ -3,13 +3,14 @@ package httpcache
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"io"
+	"iter"
+	"log/slog"
 	"net/http"
 	"slices"
 	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,6 +18,10 @@ import (
 // configurable [Store].
 type Client struct {
 	// Config is used to validate whether responses can be cached and to normalize them before storing.
+	//
+	// [Config.Clock] is also used to determine the request and response times passed to the [Store].
+	//
+	// Config is ignored after [Client.SetConfig] was called.
 	Config Config
 
 	// HTTPClient is used for sending requests that cannot be served from the cache.
@@ -26,6 +31,85 @@ type Client struct {
 
 	// Store is used to store and retrieve responses.
 	Store Store
+
+	// Partition, if set, returns the partition of a request, for example an identifier for the user sending the
+	// request. Responses stored for one partition are never used for requests in another partition. An empty string
+	// means that the request is not partitioned.
+	//
+	// The partition is passed to the [Store] using the [PartitionHeader] header. See [PartitionByHeader] and
+	// [PartitionByCookie] for common implementations.
+	//
+	// Unsafe requests invalidate the stored responses for their URL in all partitions only if the [Store] implements
+	// [URLPurger], or [StoreLister] in addition to [StoreDeleter], which requires listing all stored responses.
+	// Otherwise, only the unpartitioned responses and the responses in the partition of the request are invalidated.
+	Partition func(req *http.Request) string
+
+	// Observer, if set, is notified about all cache decisions and store operations.
+	Observer Observer
+
+	// RevalidateStaleInBackground enables revalidation of stale responses that were returned because the request
+	// allowed it using the max-stale directive.
+	//
+	// If true, a stale response is revalidated in a new goroutine after it was returned, so that following requests
+	// can use the updated response. The revalidation is canceled by [Client.Close].
+	//
+	// Only one revalidation is run for each stored response at a time. Revalidations share the concurrency limit of
+	// [RefreshAhead.Concurrency], even if refresh-ahead is disabled. Stale responses returned while the limit is
+	// reached are not revalidated.
+	RevalidateStaleInBackground bool
+
+	// RefreshAhead configures the revalidation of frequently used fresh responses in the background shortly before
+	// they become stale, so that following requests do not have to wait for the revalidation or receive a stale
+	// response.
+	//
+	// RefreshAhead must not be changed after the client was first used.
+	RefreshAhead RefreshAhead
+
+	// CanonicalEncoding enables storing a single gzip encoded representation of each response instead of separate
+	// responses for each content coding requested using the Accept-Encoding header.
+	//
+	// Unencoded responses are compressed before being stored and Accept-Encoding is removed from their Vary header.
+	// Stored responses are returned gzip encoded to requests accepting gzip and decoded to all other requests, with the
+	// Content-Encoding, Content-Length and Vary headers adjusted accordingly. Since the stored representation may differ
+	// from the one sent by the origin, strong entity tags are converted to weak entity tags.
+	//
+	// Responses with the no-transform directive, partial responses and responses using other content codings are
+	// stored as is.
+	CanonicalEncoding bool
+
+	// TagHeaders contains the names of response headers used by the origin to assign tags to responses, for example
+	// [DefaultTagHeaders].
+	//
+	// Tags are passed to the [Store] using the [TagsHeader] header and can be used to purge responses using
+	// [Client.PurgeTags]. The headers are removed from all responses returned by [Client.Do].
+	TagHeaders []string
+
+	// Tracer, if set, is used to create spans for each call to [Client.Do] as well as for store operations.
+	//
+	// If set, the request passed to the HTTPClient and the [Store] will use the context returned by the Tracer.
+	Tracer Tracer
+
+	setConfig atomic.Pointer[Config]
+
+	bg background
+}
+
+// SetConfig atomically replaces the configuration used by the client.
+//
+// SetConfig can be called while the client is in use. Requests that are already in progress, including background
+// revalidations started by them, continue to use the previous configuration.
+//
+// After SetConfig was called, the Config field is ignored.
+func (c *Client) SetConfig(config Config) {
+	c.setConfig.Store(&config)
+}
+
+// config returns the current configuration.
+func (c *Client) config() *Config {
+	if config := c.setConfig.Load(); config != nil {
+		return config
+	}
+	return &c.Config
 }
 
 // HTTPClient is the interface for types that can be used to executed requests.
@@ -43,13 +127,21 @@ func cloneHeader(h http.Header) http.Header {
 	return h2
 }
 
+// cloneResponse reads the body of the given response and returns a copy of the response.
+//
+// The body of the given response is replaced with the read body and its trailers are deferred until the new body was
+// read, see [deferTrailer].
 func cloneResponse(resp *http.Response) (*http.Response, error) {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	resp.Body = io.NopCloser(bytes.NewBuffer(body))
+	// Trailers are only available after the body was read.
+	trailer := cloneHeader(resp.Trailer)
+
+	wrapBody(resp, func(io.ReadCloser) io.ReadCloser { return io.NopCloser(bytes.NewBuffer(body)) })
+	deferTrailer(resp)
 
 	return &http.Response{
 		Status:        resp.Status,
@@ -60,7 +152,8 @@ func cloneResponse(resp *http.Response) (*http.Response, error) {
 		Header:        cloneHeader(resp.Header),
 		Body:          io.NopCloser(bytes.NewBuffer(body)),
 		ContentLength: int64(len(body)),
-		Trailer:       cloneHeader(resp.Trailer),
+		Trailer:       trailer,
+		Uncompressed:  resp.Uncompressed,
 	}, nil
 }
 
@@ -68,93 +161,137 @@ func cloneResponse(resp *http.Response) (*http.Response, error) {
 // sending an actual request and caching the returned response if possible.
 //
 // Requests for unsupported methods (by default anything other than GET, HEAD, and QUERY, see
-// [Config.SupportedRequestMethod]) will always result in an actual request without any caching involved.
+// [Config.SupportedRequestMethod]) will always result in an actual request without any caching involved. The same
+// applies to requests that include the Expect header.
 //
-// The same applies to requests that include the Expect header.
+// If the [Store] implements [URLPurger] or [StoreDeleter], a non-error response to an unsafe request (e.g. POST)
+// causes the stored responses for the same URL to be invalidated, as described in RFC 9111, Section 4.4. This includes
+// requests that bypass the cache because of their [RequestPolicy] or Expect header, as well as unsafe methods added to
+// [Config.SupportedRequestMethods].
 //
-// Errors during the parsing of request or response headers (e.g. Cache-Control) are ignored.
+// If [Client.Partition] is set, stored responses are only used for requests in the same partition.
 //
-// Stale responses will result in a conditional request with If-Modified-Since and/or If-None-Match iff the cached
-// response has the Last-Modified and/or ETag header set. Otherwise, the response will be sent as if no cached response
-// was found.
-func (c *Client) Do(req *http.Request) (*http.Response, error) {
-	client := c.HTTPClient
-	if client == nil {
-		client = http.DefaultClient
-	}
+// The URL of requests is normalized using [Config.NormalizeURL] before looking up or storing responses. Requests sent
+// to the origin always use the URL of the given request.
+//
+// Errors during the parsing of request or response headers (e.g. Cache-Control) as well as errors returned by the
+// [Store] are reported to the [Observer], if any, but otherwise ignored.
+//
+// Stale responses are returned without contacting the origin if the request allows it using the max-stale directive
+// and the response does not require revalidation via the must-revalidate, proxy-revalidate (shared caches only) or
+// no-cache directives. If [Client.RevalidateStaleInBackground] is set, the response is then revalidated in the
+// background.
+//
+// If [Client.RefreshAhead] is enabled, fresh responses that are used frequently are revalidated in the background
+// during the last part of their freshness lifetime. Background revalidations are canceled by [Client.Close].
+//
+// If the request contains the no-cache directive and [Config.RespectRequestDirectiveNoCache] is set, or if the request
+// contains the directive max-age=0, the stored response is always validated, even if it is fresh, unless the response
+// is immutable. See [Config.ExplainReload] for details.
+//
+// Other stale responses will result in a conditional request with If-Modified-Since and/or If-None-Match iff the
+// cached response has the Last-Modified and/or ETag header set. Otherwise, the response will be sent as if no cached
+// response was found. If the origin responds with 304 Not Modified, the stored response is updated using the headers
+// of the 304 response, as described in RFC 9111, Section 4.3.4.
+//
+// Responses that were transparently decompressed by the transport are stored decoded and returned from the cache with
+// [http.Response.Uncompressed] set. See [UncompressedHeader] for details.
+//
+// Before a response is checked and stored, the first matching rule in [Config.OverrideRules], if any, is applied to a
+// copy of its headers. The returned response is not modified.
+//
+// The behavior can be changed for a single request by attaching a [RequestPolicy] to the request context using
+// [WithRequestPolicy].
+//
+// Use [Info] to find out whether the returned response came from the origin or the cache. Responses returned from the
+// cache have their Request field set to the given request. Their TLS field is set to the connection state of the 304
+// response if the stored response was validated by the origin and is nil otherwise, as no connection was used.
+func (c *Client) Do(req *http.Request) (_ *http.Response, err error) {
+	ctx, span := c.startSpan(req.Context(), "httpcache.Client.Do",
+		slog.String("http.request.method", req.Method),
+		slog.String("url.full", req.URL.String()))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}()
 
-	if !c.Config.AllowsCachedResponseFor(req) {
-		return client.Do(req)
+	if c.Tracer != nil {
+		req = req.WithContext(ctx)
 	}
 
-	if len(req.Header["Expect"]) != 0 {
-		return client.Do(req)
-	}
+	// The configuration is loaded once, so that all decisions for the request use the same configuration, even if
+	// it is replaced concurrently using SetConfig.
+	config := c.config()
 
-	var reqDirectives RequestDirectives
-	if s := strings.Join(req.Header["Cache-Control"], ","); s != "" {
-		reqDirectives, _ = ParseRequestDirectives(s)
+	if reason, ok := c.bypassReason(ctx, config, req); ok {
+		c.observe(ctx, span, Event{Type: EventBypass, Request: req, Reason: reason})
+		return c.sendUncached(ctx, span, config, req)
 	}
 
-	stored, _ := c.Store.Get(req.Context(), req)
+	policy, _ := RequestPolicyFromContext(ctx)
+
+	callerReq := req
+
+	req = c.partition(req)
+
+	reqDirectives := c.requestDirectives(ctx, span, req, policy)
+
+	stored := c.storeGet(ctx, span, config, req)
+
+	missReason := "not-found"
 
 	if stored != nil {
-		age, _ := ParseAge(stored.Header.Get("Age"))
+		respDirectives := c.responseDirectives(ctx, span, req, stored)
 
-		date, _ := http.ParseTime(stored.Header.Get("Date"))
+		freshness, reason, reload := c.storedFreshness(ctx, span, config, req, reqDirectives, respDirectives, policy,
+			stored)
 
-		// From https://www.rfc-editor.org/rfc/rfc9111#name-calculating-freshness-lifet
-		//
-		// When there is more than one value present for a given directive (e.g., two Expires header field lines or
-		// multiple Cache-Control: max-age directives), either the first occurrence should be used or the response
-		// should be considered stale.
-		expires, _ := ParseExpires(stored.Header.Get("Expires"))
-
-		var respDirectives ResponseDirectives
-		if s := strings.Join(stored.Header["Cache-Control"], ","); s != "" {
-			respDirectives, _ = ParseResponseDirectives(s)
+		switch {
+		case reload:
+		case freshness == FreshnessFresh && !requiresValidation(respDirectives):
+			c.observe(ctx, span, Event{Type: EventHit, Request: req, Response: stored})
+
+			c.setStoredInfo(config, req, stored, ResponseInfo{})
+			c.refreshAhead(ctx, config, req, stored)
+			c.removeTags(stored.Header)
+			serveCanonical(req, stored)
+
+			stored.Request = callerReq
+
+			return stored, nil
+		case freshness == FreshnessStale && c.allowsStale(config, respDirectives):
+			c.observe(ctx, span, Event{Type: EventStaleServed, Request: req, Response: stored, Reason: reason.String()})
+
+			c.setStoredInfo(config, req, stored, ResponseInfo{Stale: true})
+
+			if c.RevalidateStaleInBackground {
+				c.revalidateInBackground(ctx, config, req, stored)
+			}
+
+			c.removeTags(stored.Header)
+			serveCanonical(req, stored)
+
+			stored.Request = callerReq
+
+			return stored, nil
 		}
 
-		freshnessLifetime, _ := CalculateFreshnessLifetime(
-			c.Config.Private,
-			date,
-			expires,
-			respDirectives.MaxAge,
-			respDirectives.SMaxAge)
-
-		freshness := CalculateFreshness(
-			age,
-			freshnessLifetime,
-			reqDirectives.MinFresh,
-			reqDirectives.MaxAge,
-			reqDirectives.MaxStale)
-
-		switch freshness {
-		case FreshnessExpired:
+		if !hasValidators(req, stored) {
 			stored = nil
-		case FreshnessFresh:
-			return stored, nil
-		case FreshnessStale:
-			etag := stored.Header.Get("Etag")
-			lastModified := stored.Header.Get("Last-Modified")
-
-			// Avoid cloning request if we do not modify it
-			if etag != "" || lastModified != "" {
-				req = req.Clone(req.Context())
-
-				if etag != "" {
-					req.Header.Set("If-None-Match", strings.TrimPrefix(etag, "W/"))
-				}
-
-				if lastModified != "" {
-					req.Header.Set("If-Modified-Since", lastModified)
-				}
-			} else {
-				stored = nil
+			missReason = "no-validators"
+
+			if reason != ReasonNone {
+				missReason = reason.String()
 			}
 		}
 	}
 
+	if stored == nil {
+		c.observe(ctx, span, Event{Type: EventMiss, Request: req, Reason: missReason})
+	}
+
 	if reqDirectives.OnlyIfCached {
 		return &http.Response{
 			Status:        http.StatusText(http.StatusGatewayTimeout),
@@ -166,58 +303,575 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 			Body:          nil,
 			ContentLength: 0,
 			Trailer:       http.Header{},
-			Request:       req,
-			TLS:           req.TLS,
+			Request:       callerReq,
 		}, nil
 	}
 
-	reqTime := time.Now()
+	resp, err := c.fetch(ctx, span, config, req, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	if info, _ := knownInfo(resp); info.Source == SourceCache {
+		resp.Request = callerReq
+	}
+
+	return resp, nil
+}
+
+// bypassReason returns the reason for which the given request must be sent without using the cache, if any.
+func (c *Client) bypassReason(ctx context.Context, config *Config, req *http.Request) (string, bool) {
+	if policy, _ := RequestPolicyFromContext(ctx); policy.Bypass {
+		return ReasonRequestPolicy.String(), true
+	}
+
+	// Requests with the no-cache directive must not be answered using a stored response without validation, but the
+	// stored response can still be used to send a conditional request, so that the store can be updated with the result.
+	// See Config.ExplainReload.
+	if d := config.ExplainCachedResponseFor(req); !d.Allowed && d.Reason != ReasonRequestNoCache {
+		return d.Reason.String(), true
+	}
+
+	if len(req.Header["Expect"]) != 0 {
+		return "expect", true
+	}
+
+	return "", false
+}
+
+// sendUncached sends the given request without using the cache and invalidates stored responses if the request is
+// unsafe.
+func (c *Client) sendUncached(
+	ctx context.Context,
+	span Span,
+	config *Config,
+	req *http.Request,
+) (*http.Response, error) {
+	reqTime := config.now()
+
+	// The partition is only meant for the store, but the caller may have set the header anyway.
+	outReq := req
+	if len(req.Header[PartitionHeader]) != 0 {
+		outReq = req.Clone(ctx)
+		outReq.Header.Del(PartitionHeader)
+	}
+
+	resp, err := c.httpClient().Do(outReq)
+	if err != nil {
+		return resp, err
+	}
+
+	c.setOriginInfo(config, req, reqTime, resp, config.now(), ResponseInfo{})
+
+	c.invalidate(ctx, span, config, req, resp)
+
+	return resp, nil
+}
+
+func (c *Client) httpClient() HTTPClient {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// requestDirectives parses the Cache-Control header of the request and applies the given policy.
+func (c *Client) requestDirectives(
+	ctx context.Context,
+	span Span,
+	req *http.Request,
+	policy RequestPolicy,
+) RequestDirectives {
+	var reqDirectives RequestDirectives
+	if s := strings.Join(req.Header["Cache-Control"], ","); s != "" {
+		var err error
+		reqDirectives, err = ParseRequestDirectives(s)
+		c.observeInvalidHeader(ctx, span, req, nil, "Cache-Control", err)
+	}
+	policy.apply(&reqDirectives)
+	return reqDirectives
+}
+
+// storedFreshness calculates the freshness of the stored response for the given request, like [Client.freshness], but
+// also checks whether the request or its policy requires the response to be reloaded.
+//
+// If a reload is required, the reason for it is returned together with true.
+func (c *Client) storedFreshness(
+	ctx context.Context,
+	span Span,
+	config *Config,
+	req *http.Request,
+	reqDirectives RequestDirectives,
+	respDirectives ResponseDirectives,
+	policy RequestPolicy,
+	stored *http.Response,
+) (Freshness, Reason, bool) {
+	validateReason := config.ExplainReload(reqDirectives, respDirectives)
+	if policy.Refresh {
+		validateReason = ReasonRequestPolicy
+	}
+
+	if validateReason == ReasonNone && reqDirectives.MaxAge.Valid && reqDirectives.MaxAge.Value == 0 {
+		// The reload was ignored because the response is immutable, so max-age=0 must not cause the response to be
+		// considered stale either.
+		reqDirectives.MaxAge = Opt[time.Duration]{}
+	}
+
+	freshness, reason := c.freshness(ctx, span, config, req, reqDirectives, respDirectives, policy.TTL, stored)
+
+	if validateReason != ReasonNone {
+		return freshness, validateReason, true
+	}
+
+	return freshness, reason, false
+}
+
+// responseDirectives parses the Cache-Control header of the stored response.
+func (c *Client) responseDirectives(
+	ctx context.Context,
+	span Span,
+	req *http.Request,
+	stored *http.Response,
+) ResponseDirectives {
+	var respDirectives ResponseDirectives
+	if s := strings.Join(stored.Header["Cache-Control"], ","); s != "" {
+		var err error
+		respDirectives, err = ParseResponseDirectives(s)
+		c.observeInvalidHeader(ctx, span, req, stored, "Cache-Control", err)
+	}
+	return respDirectives
+}
+
+// freshness calculates the freshness of the stored response for the given request.
+//
+// If ttl is set, it is used in place of the freshness lifetime of the response.
+func (c *Client) freshness(
+	ctx context.Context,
+	span Span,
+	config *Config,
+	req *http.Request,
+	reqDirectives RequestDirectives,
+	respDirectives ResponseDirectives,
+	ttl Opt[time.Duration],
+	stored *http.Response,
+) (Freshness, Reason) {
+	var age time.Duration
+	if s := stored.Header.Get("Age"); s != "" {
+		var err error
+		age, err = ParseAge(s)
+		c.observeInvalidHeader(ctx, span, req, stored, "Age", err)
+	}
+
+	date, err := http.ParseTime(stored.Header.Get("Date"))
+	if err != nil {
+		// Responses stored by the Client always have a Date header, but responses stored by other means may not.
+		// In this case approximate the time the response was received using the age of the response, as allowed
+		// by RFC 9110, Section 6.6.1.
+		date = config.now().Add(-age)
+	}
+
+	// From https://www.rfc-editor.org/rfc/rfc9111#name-calculating-freshness-lifet
+	//
+	// When there is more than one value present for a given directive (e.g., two Expires header field lines or
+	// multiple Cache-Control: max-age directives), either the first occurrence should be used or the response
+	// should be considered stale.
+	var expires time.Time
+	if s := stored.Header.Get("Expires"); s != "" {
+		expires, err = ParseExpires(s)
+		c.observeInvalidHeader(ctx, span, req, stored, "Expires", err)
+	}
+
+	freshnessLifetime, _ := CalculateFreshnessLifetime(
+		config.Private,
+		date,
+		expires,
+		respDirectives.MaxAge,
+		respDirectives.SMaxAge)
+
+	if ttl.Valid {
+		freshnessLifetime = ttl.Value
+	}
+
+	freshness, reason := ExplainFreshness(
+		age,
+		freshnessLifetime,
+		reqDirectives.MinFresh,
+		reqDirectives.MaxAge,
+		reqDirectives.MaxStale)
+
+	return freshness, reason
+}
+
+// requiresValidation returns true if the response must be validated before each use because of the no-cache
+// directive.
+//
+// A no-cache directive with header names only restricts the use of those headers and is ignored.
+func requiresValidation(respDirectives ResponseDirectives) bool {
+	return respDirectives.NoCache && respDirectives.NoCacheHeaders == nil
+}
+
+// allowsStale returns true if a stale response with the given directives may be used without validation.
+func (c *Client) allowsStale(config *Config, respDirectives ResponseDirectives) bool {
+	if respDirectives.MustRevalidate || requiresValidation(respDirectives) {
+		return false
+	}
+
+	// From https://www.rfc-editor.org/rfc/rfc9111#name-s-maxage
+	//
+	// The s-maxage directive incorporates the semantics of the proxy-revalidate response directive (Section 5.2.2.8)
+	// for a shared cache.
+	if !config.Private && (respDirectives.ProxyRevalidate || respDirectives.SMaxAge.Valid) {
+		return false
+	}
+
+	return true
+}
+
+// hasValidators returns true if the stored response has validators that can be used in a conditional request for req.
+func hasValidators(req *http.Request, stored *http.Response) bool {
+	etag, lastModified := validators(req, stored)
+	return etag != "" || lastModified != ""
+}
+
+// conditionalRequest returns a copy of req with If-None-Match and If-Modified-Since set based on the stored response.
+func conditionalRequest(req *http.Request, stored *http.Response) *http.Request {
+	etag, lastModified := validators(req, stored)
+
+	req = req.Clone(req.Context())
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	return req
+}
+
+// fetch sends the given request, using a conditional request if stored is not nil, and stores the response if
+// possible.
+func (c *Client) fetch(
+	ctx context.Context,
+	span Span,
+	config *Config,
+	req *http.Request,
+	stored *http.Response,
+) (*http.Response, error) {
+	outReq := req
+	if stored != nil {
+		outReq = conditionalRequest(req, stored)
+	}
+
+	// The partition is only meant for the store.
+	if len(outReq.Header[PartitionHeader]) != 0 {
+		if outReq == req {
+			outReq = req.Clone(ctx)
+		}
+		outReq.Header.Del(PartitionHeader)
+	}
+
+	reqTime := config.now()
 
 	//goland:noinspection GoResourceLeak
-	resp, err := client.Do(req)
+	resp, err := c.httpClient().Do(outReq)
 	if err != nil {
 		return nil, err
 	}
 
-	respTime := time.Now()
+	respTime := config.now()
 
-	if stored != nil && resp.StatusCode == http.StatusNotModified {
-		return stored, nil
+	c.invalidate(ctx, span, config, req, resp)
+
+	if stored != nil {
+		if resp.StatusCode == http.StatusNotModified {
+			_ = resp.Body.Close()
+
+			c.observe(ctx, span, Event{Type: EventRevalidated, Request: req, Response: stored})
+
+			return c.freshen(ctx, span, config, req, reqTime, stored, resp, respTime)
+		}
+
+		c.observe(ctx, span, Event{Type: EventMiss, Request: req, Response: resp, Reason: "validation-failed"})
 	}
 
-	if c.Config.AllowsStoringResponse(resp) {
-		respCopy, err := cloneResponse(resp)
-		if err != nil {
+	var info ResponseInfo
+	if stored != nil {
+		info.Revalidation = RevalidationModified
+	}
+
+	// Override rules are applied to a copy of the response, so that the returned response is not changed.
+	overridden := resp
+
+	rule, hasRule := config.OverrideRuleFor(req, resp.StatusCode)
+	if hasRule {
+		overridden = new(http.Response)
+		*overridden = *resp
+		overridden.Header = cloneHeader(resp.Header)
+
+		config.ApplyOverrideRule(rule, overridden.Header)
+	}
+
+	if d := config.ExplainStoringResponse(overridden); !d.Allowed && !rule.forcesStore(d.Reason) {
+		c.observe(ctx, span, Event{Type: EventNotStored, Request: req, Response: resp, Reason: d.Reason.String()})
+
+		c.removeTags(resp.Header)
+		c.setOriginInfo(config, req, reqTime, resp, respTime, info)
+
+		return resp, nil
+	}
+
+	respCopy, err := cloneResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasRule {
+		respCopy.Header = overridden.Header
+	}
+
+	config.RemoveUnstorableHeaders(respCopy.Header)
+	config.RemoveUnstorableHeaders(respCopy.Trailer)
+
+	// Tags can only be assigned using the configured headers.
+	respCopy.Header.Del(TagsHeader)
+	c.collectTags(respCopy.Header)
+
+	markUncompressed(respCopy.Header, resp.Uncompressed)
+
+	respCopy.Header.Del(CanonicalEncodingHeader)
+
+	if c.CanonicalEncoding {
+		if err := canonicalize(req, respCopy); err != nil {
 			return nil, err
 		}
+	}
 
-		c.Config.RemoveUnstorableHeaders(respCopy.Header)
+	addDate(respCopy.Header, respTime)
+	markStored(respCopy.Header, respTime)
 
-		_ = c.Store.Set(req.Context(), req, reqTime, respCopy, respTime)
+	if c.storeSet(ctx, span, config, req, reqTime, respCopy, respTime) {
+		c.observe(ctx, span, Event{Type: EventStored, Request: req, Response: resp})
+
+		info.StoredAt = respTime
 	}
 
+	c.removeTags(resp.Header)
+	c.setOriginInfo(config, req, reqTime, resp, respTime, info)
+
 	return resp, nil
 }
 
+// addDate adds a Date header with the given time if the header has no valid Date.
+func addDate(h http.Header, respTime time.Time) {
+	// From https://www.rfc-editor.org/rfc/rfc9110#section-6.6.1
+	//
+	// A recipient with a clock that receives a response message without a Date header field MUST add one if it is
+	// cached or forwarded downstream.
+	if _, err := http.ParseTime(h.Get("Date")); err != nil {
+		h.Set("Date", respTime.UTC().Format(http.TimeFormat))
+	}
+}
+
+// freshen updates the stored response using the headers from a 304 response, stores the updated response and returns
+// it.
+func (c *Client) freshen(
+	ctx context.Context,
+	span Span,
+	config *Config,
+	req *http.Request, reqTime time.Time,
+	stored *http.Response,
+	notModified *http.Response, respTime time.Time,
+) (*http.Response, error) {
+	updated, err := cloneResponse(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	// From https://www.rfc-editor.org/rfc/rfc9111#name-updating-stored-header-fiel
+	//
+	// Caches are required to update a stored response's header fields from another (typically newer) response in
+	// several situations; for example, see Sections 3.4, 4.3.4, and 4.3.5.
+	//
+	// When doing so, the cache MUST add each header field in the provided response to the stored response, replacing
+	// field values that are already present, with the following exceptions:
+	//
+	// - Header fields excepted from storage in Section 3.1,
+	// - Header fields that the cache's stored response depends on, as described below,
+	// - Header fields that are automatically processed and removed by the recipient, as described below, and
+	// - The Content-Length header field.
+	header := cloneHeader(notModified.Header)
+	config.RemoveUnstorableHeaders(header)
+	delete(header, "Content-Length")
+	delete(header, UncompressedHeader)
+	delete(header, CanonicalEncodingHeader)
+
+	// The age and date of the stored response are reset by the validation and replaced by those of the 304 response,
+	// if any.
+	delete(updated.Header, "Age")
+	delete(updated.Header, "Date")
+
+	for name, values := range header {
+		updated.Header[name] = values
+	}
+
+	if rule, ok := config.OverrideRuleFor(req, updated.StatusCode); ok {
+		config.ApplyOverrideRule(rule, updated.Header)
+	}
+
+	if _, ok := updated.Header[CanonicalEncodingHeader]; ok {
+		canonicalizeHeader(updated.Header)
+	}
+
+	c.collectTags(updated.Header)
+
+	addDate(updated.Header, respTime)
+
+	var respAge Opt[time.Duration]
+	if s := updated.Header.Get("Age"); s != "" {
+		respAge.Value, err = ParseAge(s)
+		respAge.Valid = err == nil
+	}
+
+	respDate, _ := http.ParseTime(updated.Header.Get("Date"))
+
+	markStored(updated.Header, respTime)
+
+	stored.Header = cloneHeader(updated.Header)
+	stored.Header.Set("Age", formatAge(CalculateAge(respTime, reqTime, respAge, respDate, respTime)))
+	stored.TLS = notModified.TLS
+
+	c.setStoredInfo(config, req, stored, ResponseInfo{Revalidation: RevalidationNotModified})
+	c.removeTags(stored.Header)
+
+	markUncompressed(updated.Header, stored.Uncompressed)
+
+	serveCanonical(req, stored)
+
+	c.storeSet(ctx, span, config, req, reqTime, updated, respTime)
+
+	return stored, nil
+}
+
+func formatAge(age time.Duration) string {
+	return strconv.Itoa(int(age.Seconds()))
+}
+
+func (c *Client) observe(ctx context.Context, span Span, e Event) {
+	if policy, ok := RequestPolicyFromContext(ctx); ok {
+		e.Policy = &policy
+	}
+
+	attrs := []slog.Attr{slog.String("httpcache.event", e.Type.String())}
+	if e.Reason != "" {
+		attrs = append(attrs, slog.String("httpcache.reason", e.Reason))
+	}
+	if e.Policy != nil {
+		attrs = append(attrs, slog.String("httpcache.policy", e.Policy.String()))
+	}
+	span.AddEvent("httpcache."+e.Type.String(), attrs...)
+
+	if c.Observer != nil {
+		c.Observer.Observe(ctx, e)
+	}
+}
+
+func (c *Client) observeInvalidHeader(
+	ctx context.Context,
+	span Span,
+	req *http.Request,
+	resp *http.Response,
+	name string,
+	err error,
+) {
+	if err == nil {
+		return
+	}
+
+	c.observe(ctx, span, Event{Type: EventInvalidHeader, Request: req, Response: resp, Reason: name, Err: err})
+}
+
+func (c *Client) startSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	if c.Tracer == nil {
+		return ctx, nopSpan{}
+	}
+	return c.Tracer.Start(ctx, name, attrs...)
+}
+
+func (c *Client) storeGet(ctx context.Context, parent Span, config *Config, req *http.Request) *http.Response {
+	ctx, span := c.startSpan(ctx, "httpcache.Store.Get")
+	defer span.End()
+
+	req = normalize(config, req)
+
+	start := time.Now()
+
+	resp, err := c.Store.Get(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	c.observe(ctx, parent, Event{Type: EventStoreGet, Request: req, Response: resp, Duration: time.Since(start), Err: err})
+
+	if err != nil || resp == nil {
+		return nil
+	}
+
+	restoreUncompressed(resp)
+	deferTrailer(resp)
+
+	return resp
+}
+
+func (c *Client) storeSet(
+	ctx context.Context,
+	parent Span,
+	config *Config,
+	req *http.Request, reqTime time.Time,
+	resp *http.Response, respTime time.Time,
+) bool {
+	ctx, span := c.startSpan(ctx, "httpcache.Store.Set")
+	defer span.End()
+
+	req = normalize(config, req)
+
+	start := time.Now()
+
+	err := c.Store.Set(ctx, req, reqTime, resp, respTime)
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	c.observe(ctx, parent, Event{Type: EventStoreSet, Request: req, Response: resp, Duration: time.Since(start), Err: err})
+
+	return err == nil
+}
+
 // Store defines the interface used by [Client] for storing and retrieving responses.
 //
-// A store must handle storing and retrieving requests based on their method, URL and headers specified in the Vary
-// response header.
+// A store must handle storing and retrieving requests based on their method, URL, [PartitionHeader] and headers
+// specified in the Vary response header.
 //
 // A store must be safe for concurrent use by multiple goroutines.
+//
+// Implementations can be tested using [github.com/nussjustin/httpcache/storetest.Run].
 type Store interface {
 	// Get returns the stored response matching the given request.
 	//
 	// The given request must not be modified.
 	//
-	// The response must include an Age header containing the age of the response.
+	// The response must include an Age header containing the age of the response, as calculated by [CalculateAge].
 	Get(ctx context.Context, req *http.Request) (resp *http.Response, err error)
 
 	// Set stores the given response in the cache.
 	//
 	// The given request must not be modified.
 	//
-	// The response body is guaranteed to be readable without errors.
+	// The response body is guaranteed to be readable without errors. The trailers of the response must only be read
+	// after the body was read.
+	//
+	// If the response has no valid Date header, respTime must be used in its place when calculating the age.
 	Set(
 		ctx context.Context,
 		req *http.Request, reqTime time.Time,
@@ -225,58 +879,151 @@ type Store interface {
 	) error
 }
 
-type memoryStore struct {
-	entriesMu sync.RWMutex
-	entries   map[string][]*memoryStoreEntry
+// MemoryStore is a [Store] that stores responses in memory.
+//
+// This is only meant for testing.
+//
+// There is no limit to the number of stored responses and expired responses are never removed.
+//
+// MemoryStore implements the optional [StoreLister], [StoreDeleter], [StoreStatsReporter], [TagPurger],
+// [PartitionPurger] and [URLPurger] interfaces.
+//
+// The zero value is ready to use.
+type MemoryStore struct {
+	// Clock is used to calculate the age of stored responses.
+	//
+	// If nil, [time.Now] is used.
+	Clock Clock
+
+	// MaxPartitionEntries, if greater than zero, limits the number of responses stored per partition (see
+	// [PartitionHeader]). When the limit is exceeded, the oldest responses of the partition are removed.
+	MaxPartitionEntries int
+
+	shard memoryShard
 }
 
 type memoryStoreEntry struct {
-	req      http.Request
-	reqTime  time.Time
-	resp     http.Response
-	respBody []byte
-	respTime time.Time
-	vary     Vary
-	varyKey  string
+	key       string
+	partition string
+
+	// urlKey is the key of the entry without the partition. It is only set for partitioned entries.
+	urlKey string
+
+	req         http.Request
+	reqTime     time.Time
+	resp        http.Response
+	respBody    []byte
+	respTrailer http.Header
+	respTime    time.Time
+	initialAge  time.Duration
+	vary        Vary
+	varyKey     string
+	tags        []string
+	purged      atomic.Bool
 }
 
-// NewMemoryStore returns a Store that stores responses in memory.
-//
-// This is only meant for testing.
-//
-// There is no limit to the number of stored responses and expired responses are never removed.
-func NewMemoryStore() Store {
-	return &memoryStore{}
+// NewMemoryStore returns a new [MemoryStore] that uses [time.Now] for all age calculations.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
 }
 
-func (m *memoryStore) key(req *http.Request) string {
-	return fmt.Sprintf("%q %q", req.Method, req.URL.String())
+func memoryStoreKey(req *http.Request) string {
+	return memoryKey(req.Method, req.URL.String(), req.Header.Get(PartitionHeader))
 }
 
-func (m *memoryStore) Get(_ context.Context, req *http.Request) (resp *http.Response, err error) {
-	key := m.key(req)
+func memoryKey(method, url, partition string) string {
+	// The key is built using strconv instead of fmt, since this is done on every lookup.
+	key := make([]byte, 0, len(method)+len(url)+len(partition)+8)
+	key = strconv.AppendQuote(key, method)
+	key = append(key, ' ')
+	key = strconv.AppendQuote(key, url)
 
-	m.entriesMu.RLock()
-	defer m.entriesMu.RUnlock()
+	if partition != "" {
+		key = append(key, ' ')
+		key = strconv.AppendQuote(key, partition)
+	}
 
-	entries := m.entries[key]
+	return string(key)
+}
 
-	for _, entry := range entries {
-		varyKey := entry.vary.Key(nil, req.Header)
+func (m *MemoryStore) Get(_ context.Context, req *http.Request) (resp *http.Response, err error) {
+	return m.shard.get(memoryStoreKey(req), req, nowFrom(m.Clock)), nil
+}
 
-		if entry.varyKey != string(varyKey) {
-			continue
-		}
+// newMemoryStoreEntry reads the given response and returns a new entry for it.
+//
+// Entries are never modified after creation, except for being marked as purged, so that they can be used without
+// holding any locks.
+//
+// If the response can not be stored, because it varies on all request headers, nil is returned.
+func newMemoryStoreEntry(
+	req *http.Request, reqTime time.Time,
+	resp *http.Response, respTime time.Time,
+) (*memoryStoreEntry, error) {
+	vary := ParseVary(resp.Header["Vary"])
 
-		return entry.restore(), nil
+	if vary.Wildcard() {
+		return nil, nil
 	}
 
-	return nil, nil
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		// TODO: Test
+		return nil, err
+	}
+
+	var respAge Opt[time.Duration]
+	if s := resp.Header.Get("Age"); s != "" {
+		respAge.Value, err = ParseAge(s)
+		respAge.Valid = err == nil
+	}
+
+	respDate, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		respDate = respTime
+	}
+
+	entry := &memoryStoreEntry{
+		key:         memoryStoreKey(req),
+		partition:   req.Header.Get(PartitionHeader),
+		req:         *req,
+		reqTime:     reqTime,
+		resp:        *resp,
+		respBody:    respBody,
+		respTrailer: cloneHeader(resp.Trailer),
+		respTime:    respTime,
+		initialAge:  CalculateAge(respTime, reqTime, respAge, respDate, respTime),
+		vary:        vary,
+		varyKey:     string(vary.Key(nil, req.Header)),
+		tags:        ParseTags(resp.Header[TagsHeader]),
+	}
+
+	if entry.partition != "" {
+		entry.urlKey = memoryKey(req.Method, req.URL.String(), "")
+	}
+
+	return entry, nil
+}
+
+func (e *memoryStoreEntry) age(now time.Time) time.Duration {
+	if e.purged.Load() {
+		// Soft purged responses must be considered stale. Use the largest age required to be supported by caches,
+		// which is larger than any sensible freshness lifetime.
+		//
+		// From https://www.rfc-editor.org/rfc/rfc9111#section-1.2.2:
+		//
+		// If a cache receives a delta-seconds value greater than the greatest integer it can represent, or if any of
+		// its subsequent calculations overflows, the cache MUST consider the value to be 2147483648 (2^31) or the
+		// greatest positive integer it can conveniently represent.
+		return 2147483648 * time.Second
+	}
+
+	return e.initialAge + now.Sub(e.respTime)
 }
 
-func (e *memoryStoreEntry) restore() *http.Response {
+func (e *memoryStoreEntry) restore(now time.Time) *http.Response {
 	header := cloneHeader(e.resp.Header)
-	header.Set("Age", strconv.Itoa(int(time.Since(e.respTime).Seconds())))
+	header.Set("Age", formatAge(e.age(now)))
 
 	return &http.Response{
 		Status:        e.resp.Status,
@@ -287,58 +1034,62 @@ func (e *memoryStoreEntry) restore() *http.Response {
 		Header:        header,
 		Body:          io.NopCloser(bytes.NewReader(e.respBody)),
 		ContentLength: int64(len(e.respBody)),
-		Trailer:       cloneHeader(e.resp.Trailer),
+		Trailer:       cloneHeader(e.respTrailer),
 	}
 }
 
-func (m *memoryStore) Set(
+func (m *MemoryStore) Set(
 	_ context.Context,
 	req *http.Request, reqTime time.Time,
 	resp *http.Response, respTime time.Time,
 ) error {
-	vary := ParseVary(resp.Header["Vary"])
-
-	if vary.Wildcard() {
-		return nil
+	entry, err := newMemoryStoreEntry(req, reqTime, resp, respTime)
+	if entry == nil || err != nil {
+		return err
 	}
 
-	varyKey := string(vary.Key(nil, req.Header))
+	m.shard.set(entry, m.MaxPartitionEntries)
 
-	key := m.key(req)
+	return nil
+}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		// TODO: Test
-		return err
-	}
+// PurgeTags implements the [TagPurger] interface.
+func (m *MemoryStore) PurgeTags(_ context.Context, mode PurgeMode, tags ...string) (int, error) {
+	return m.shard.purgeTags(mode, tags), nil
+}
 
-	entry := &memoryStoreEntry{
-		req:      *req,
-		reqTime:  reqTime,
-		resp:     *resp,
-		respBody: respBody,
-		respTime: respTime,
-		vary:     vary,
-		varyKey:  varyKey,
+// Entries implements the [StoreLister] interface.
+func (m *MemoryStore) Entries(context.Context) iter.Seq2[StoreEntry, error] {
+	return func(yield func(StoreEntry, error) bool) {
+		yieldStoreEntries(m.shard.appendEntries(nil, nowFrom(m.Clock)), yield)
 	}
+}
 
-	m.entriesMu.Lock()
-	defer m.entriesMu.Unlock()
+// Delete implements the [StoreDeleter] interface.
+func (m *MemoryStore) Delete(_ context.Context, key string) (int, error) {
+	return m.shard.delete(key), nil
+}
 
-	entries := m.entries[key]
+// DeleteRequest implements the [StoreDeleter] interface.
+func (m *MemoryStore) DeleteRequest(ctx context.Context, req *http.Request) (int, error) {
+	return m.Delete(ctx, memoryStoreKey(req))
+}
 
-	for i := range entries {
-		if entries[i].varyKey == varyKey {
-			entries[i] = entry
-			return nil
-		}
-	}
+// PurgeURL implements the [URLPurger] interface.
+func (m *MemoryStore) PurgeURL(_ context.Context, method, url string) (int, error) {
+	return m.shard.purgeURL(memoryKey(method, url, "")), nil
+}
 
-	if m.entries == nil {
-		m.entries = make(map[string][]*memoryStoreEntry)
+// PurgePartition implements the [PartitionPurger] interface.
+func (m *MemoryStore) PurgePartition(_ context.Context, partition string) (int, error) {
+	if partition == "" {
+		return 0, nil
 	}
 
-	m.entries[key] = append(entries, entry)
+	return m.shard.purgePartition(partition), nil
+}
 
-	return nil
+// Stats implements the [StoreStatsReporter] interface.
+func (m *MemoryStore) Stats(context.Context) (StoreStats, error) {
+	return m.shard.stats(StoreStats{}), nil
 }
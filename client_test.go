@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/nussjustin/httpcache"
+	"github.com/nussjustin/httpcache/storetest"
 )
 
 func headerEqual(a, b http.Header) bool {
@@ -185,6 +186,7 @@ func TestClient_Do(t *testing.T) {
 					wantResp: newResp(
 						withRespHeader("Age", "60"),
 						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:00:00 GMT"),
 						withRespHeader("Transaction-Id", "0")),
 				},
 			},
@@ -212,18 +214,83 @@ func TestClient_Do(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "expires without date",
+			txs: []transaction{
+				{
+					req:        newReq(),
+					resp:       newResp(withRespHeader("Expires", "Sat, 01 Jan 2000 00:00:30 GMT")),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Expires", "Sat, 01 Jan 2000 00:00:30 GMT"),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:        newReq(),
+					resp:       newResp(withRespHeader("Expires", "Sat, 01 Jan 2000 00:03:00 GMT")),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Expires", "Sat, 01 Jan 2000 00:03:00 GMT"),
+						withRespHeader("Transaction-Id", "1")),
+				},
+				{
+					req:     newReq(),
+					wantReq: newReq(),
+					wantResp: newResp(
+						withRespHeader("Age", "60"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:01:00 GMT"),
+						withRespHeader("Expires", "Sat, 01 Jan 2000 00:03:00 GMT"),
+						withRespHeader("Transaction-Id", "1")),
+				},
+			},
+		},
+		{
+			name: "age from origin",
+			txs: []transaction{
+				{
+					req: newReq(),
+					resp: newResp(
+						withRespHeader("Age", "30"),
+						withRespHeader("Cache-Control", "public, max-age=120")),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Age", "30"),
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:     newReq(),
+					wantReq: newReq(),
+					wantResp: newResp(
+						withRespHeader("Age", "90"),
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:00:00 GMT"),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:        newReq(),
+					resp:       newResp(withRespHeader("Cache-Control", "public, max-age=120")),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Transaction-Id", "2")),
+				},
+			},
+		},
 		{
 			name: "stale cached response validated by etag",
 			txs: []transaction{
 				{
-					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					req: newReq(),
 					resp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my tag"`)),
 					wantStored: 1,
-					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					wantReq:    newReq(),
 					wantResp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my tag"`),
@@ -231,17 +298,17 @@ func TestClient_Do(t *testing.T) {
 				},
 				{
 					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-None-Match", `"my tag"`)),
-					resp: newResp(withRespStatus(http.StatusNotModified)),
+					resp:       newResp(withRespStatus(http.StatusNotModified)),
+					wantStored: 1,
 					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-None-Match", `"my tag"`)),
 					wantResp: newResp(
-						withRespHeader("Age", "60"),
+						withRespHeader("Age", "0"),
 						withRespHeader("Cache-Control", "public, max-age=60"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:01:00 GMT"),
 						withRespHeader("Etag", `"my tag"`),
-						withRespHeader("Transaction-Id", "0")),
+						withRespHeader("Transaction-Id", "1")),
 				},
 			},
 		},
@@ -249,14 +316,12 @@ func TestClient_Do(t *testing.T) {
 			name: "stale cached response validated by weak etag",
 			txs: []transaction{
 				{
-					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					req: newReq(),
 					resp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `W/"my tag"`)),
 					wantStored: 1,
-					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					wantReq:    newReq(),
 					wantResp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `W/"my tag"`),
@@ -264,17 +329,17 @@ func TestClient_Do(t *testing.T) {
 				},
 				{
 					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-None-Match", `"my tag"`)),
-					resp: newResp(withRespStatus(http.StatusNotModified)),
+					resp:       newResp(withRespStatus(http.StatusNotModified)),
+					wantStored: 1,
 					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-None-Match", `"my tag"`)),
 					wantResp: newResp(
-						withRespHeader("Age", "60"),
+						withRespHeader("Age", "0"),
 						withRespHeader("Cache-Control", "public, max-age=60"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:01:00 GMT"),
 						withRespHeader("Etag", `W/"my tag"`),
-						withRespHeader("Transaction-Id", "0")),
+						withRespHeader("Transaction-Id", "1")),
 				},
 			},
 		},
@@ -282,14 +347,12 @@ func TestClient_Do(t *testing.T) {
 			name: "failed validation by etag",
 			txs: []transaction{
 				{
-					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					req: newReq(),
 					resp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my tag"`)),
 					wantStored: 1,
-					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					wantReq:    newReq(),
 					wantResp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my tag"`),
@@ -297,14 +360,12 @@ func TestClient_Do(t *testing.T) {
 				},
 				{
 					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-None-Match", `"my tag"`)),
 					resp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"other tag"`)),
 					wantStored: 1,
 					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-None-Match", `"my tag"`)),
 					wantResp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
@@ -317,14 +378,12 @@ func TestClient_Do(t *testing.T) {
 			name: "stale cached response validated by last-modified",
 			txs: []transaction{
 				{
-					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					req: newReq(),
 					resp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Last-Modified", `Mon, 02 Jan 2006 15:04:05 GMT`)),
 					wantStored: 1,
-					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					wantReq:    newReq(),
 					wantResp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Last-Modified", `Mon, 02 Jan 2006 15:04:05 GMT`),
@@ -332,17 +391,17 @@ func TestClient_Do(t *testing.T) {
 				},
 				{
 					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-Modified-Since", `Mon, 02 Jan 2006 15:04:05 GMT`)),
-					resp: newResp(withRespStatus(http.StatusNotModified)),
+					resp:       newResp(withRespStatus(http.StatusNotModified)),
+					wantStored: 1,
 					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-Modified-Since", `Mon, 02 Jan 2006 15:04:05 GMT`)),
 					wantResp: newResp(
-						withRespHeader("Age", "60"),
+						withRespHeader("Age", "0"),
 						withRespHeader("Cache-Control", "public, max-age=60"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:01:00 GMT"),
 						withRespHeader("Last-Modified", `Mon, 02 Jan 2006 15:04:05 GMT`),
-						withRespHeader("Transaction-Id", "0")),
+						withRespHeader("Transaction-Id", "1")),
 				},
 			},
 		},
@@ -350,14 +409,12 @@ func TestClient_Do(t *testing.T) {
 			name: "failed validation by last-modified",
 			txs: []transaction{
 				{
-					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					req: newReq(),
 					resp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Last-Modified", `Mon, 02 Jan 2006 15:04:05 GMT`)),
 					wantStored: 1,
-					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					wantReq:    newReq(),
 					wantResp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Last-Modified", `Mon, 02 Jan 2006 15:04:05 GMT`),
@@ -365,14 +422,12 @@ func TestClient_Do(t *testing.T) {
 				},
 				{
 					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-Modified-Since", `Mon, 02 Jan 2006 15:04:05 GMT`)),
 					resp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Last-Modified", `Mon, 03 Jan 2006 15:04:05 GMT`)),
 					wantStored: 1,
 					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-Modified-Since", `Mon, 02 Jan 2006 15:04:05 GMT`)),
 					wantResp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
@@ -385,15 +440,13 @@ func TestClient_Do(t *testing.T) {
 			name: "stale cached response validated by etag and last-modified",
 			txs: []transaction{
 				{
-					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					req: newReq(),
 					resp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my etag"`),
 						withRespHeader("Last-Modified", `Mon, 02 Jan 2006 15:04:05 GMT`)),
 					wantStored: 1,
-					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					wantReq:    newReq(),
 					wantResp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my etag"`),
@@ -402,36 +455,421 @@ func TestClient_Do(t *testing.T) {
 				},
 				{
 					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-Modified-Since", `Mon, 02 Jan 2006 15:04:05 GMT`),
 						withReqHeader("If-None-Match", `"my etag"`)),
-					resp: newResp(withRespStatus(http.StatusNotModified)),
+					resp:       newResp(withRespStatus(http.StatusNotModified)),
+					wantStored: 1,
 					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-Modified-Since", `Mon, 02 Jan 2006 15:04:05 GMT`),
 						withReqHeader("If-None-Match", `"my etag"`)),
 					wantResp: newResp(
-						withRespHeader("Age", "60"),
+						withRespHeader("Age", "0"),
 						withRespHeader("Cache-Control", "public, max-age=60"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:01:00 GMT"),
 						withRespHeader("Etag", `"my etag"`),
 						withRespHeader("Last-Modified", `Mon, 02 Jan 2006 15:04:05 GMT`),
+						withRespHeader("Transaction-Id", "1")),
+				},
+			},
+		},
+		{
+			name: "stale cached response served within max-stale",
+			txs: []transaction{
+				{
+					req:        newReq(),
+					resp:       newResp(withRespHeader("Cache-Control", "public, max-age=30")),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=30"),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:     newReq(withReqHeader("Cache-Control", "max-stale=60")),
+					wantReq: newReq(withReqHeader("Cache-Control", "max-stale=60")),
+					wantResp: newResp(
+						withRespHeader("Age", "60"),
+						withRespHeader("Cache-Control", "public, max-age=30"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:00:00 GMT"),
+						withRespHeader("Transaction-Id", "0")),
+				},
+			},
+		},
+		{
+			name: "stale cached response served with max-stale without value",
+			txs: []transaction{
+				{
+					req:        newReq(),
+					resp:       newResp(withRespHeader("Cache-Control", "public, max-age=30")),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=30"),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:     newReq(withReqHeader("Cache-Control", "max-stale")),
+					wantReq: newReq(withReqHeader("Cache-Control", "max-stale")),
+					wantResp: newResp(
+						withRespHeader("Age", "60"),
+						withRespHeader("Cache-Control", "public, max-age=30"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:00:00 GMT"),
 						withRespHeader("Transaction-Id", "0")),
 				},
 			},
 		},
+		{
+			name: "stale cached response not served beyond max-stale",
+			txs: []transaction{
+				{
+					req:        newReq(),
+					resp:       newResp(withRespHeader("Cache-Control", "public, max-age=30")),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=30"),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:        newReq(withReqHeader("Cache-Control", "max-stale=10")),
+					resp:       newResp(withRespHeader("Cache-Control", "public, max-age=30")),
+					wantStored: 1,
+					wantReq:    newReq(withReqHeader("Cache-Control", "max-stale=10")),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=30"),
+						withRespHeader("Transaction-Id", "1")),
+				},
+			},
+		},
+		{
+			name: "stale cached response with must-revalidate validated despite max-stale",
+			txs: []transaction{
+				{
+					req: newReq(),
+					resp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=30, must-revalidate"),
+						withRespHeader("Etag", `"my tag"`)),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=30, must-revalidate"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:        newReq(withReqHeader("Cache-Control", "max-stale")),
+					resp:       newResp(withRespStatus(http.StatusNotModified)),
+					wantStored: 1,
+					wantReq: newReq(
+						withReqHeader("Cache-Control", "max-stale"),
+						withReqHeader("If-None-Match", `"my tag"`)),
+					wantResp: newResp(
+						withRespHeader("Age", "0"),
+						withRespHeader("Cache-Control", "public, max-age=30, must-revalidate"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:01:00 GMT"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "1")),
+				},
+			},
+		},
+		{
+			name: "fresh cached response with no-cache validated",
+			txs: []transaction{
+				{
+					req: newReq(),
+					resp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120, no-cache"),
+						withRespHeader("Etag", `"my tag"`)),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120, no-cache"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:        newReq(),
+					resp:       newResp(withRespStatus(http.StatusNotModified)),
+					wantStored: 1,
+					wantReq:    newReq(withReqHeader("If-None-Match", `"my tag"`)),
+					wantResp: newResp(
+						withRespHeader("Age", "0"),
+						withRespHeader("Cache-Control", "public, max-age=120, no-cache"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:01:00 GMT"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "1")),
+				},
+			},
+		},
+		{
+			name:   "request no-cache validates fresh cached response",
+			config: httpcache.Config{RespectRequestDirectiveNoCache: true},
+			txs: []transaction{
+				{
+					req: newReq(),
+					resp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Etag", `"my tag"`)),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:        newReq(withReqHeader("Cache-Control", "no-cache")),
+					resp:       newResp(withRespStatus(http.StatusNotModified)),
+					wantStored: 1,
+					wantReq: newReq(
+						withReqHeader("Cache-Control", "no-cache"),
+						withReqHeader("If-None-Match", `"my tag"`)),
+					wantResp: newResp(
+						withRespHeader("Age", "0"),
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:01:00 GMT"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "1")),
+				},
+				{
+					req:     newReq(),
+					wantReq: newReq(),
+					wantResp: newResp(
+						withRespHeader("Age", "60"),
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:01:00 GMT"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "1")),
+				},
+			},
+		},
+		{
+			name:   "request no-cache replaces fresh cached response",
+			config: httpcache.Config{RespectRequestDirectiveNoCache: true},
+			txs: []transaction{
+				{
+					req: newReq(),
+					resp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Etag", `"my tag"`)),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req: newReq(withReqHeader("Cache-Control", "no-cache")),
+					resp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Etag", `"other tag"`)),
+					wantStored: 1,
+					wantReq: newReq(
+						withReqHeader("Cache-Control", "no-cache"),
+						withReqHeader("If-None-Match", `"my tag"`)),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Etag", `"other tag"`),
+						withRespHeader("Transaction-Id", "1")),
+				},
+				{
+					req:     newReq(),
+					wantReq: newReq(),
+					wantResp: newResp(
+						withRespHeader("Age", "60"),
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:01:00 GMT"),
+						withRespHeader("Etag", `"other tag"`),
+						withRespHeader("Transaction-Id", "1")),
+				},
+			},
+		},
+		{
+			name:   "request no-cache without validators",
+			config: httpcache.Config{RespectRequestDirectiveNoCache: true},
+			txs: []transaction{
+				{
+					req: newReq(),
+					resp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120")),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:        newReq(withReqHeader("Cache-Control", "no-cache")),
+					resp:       newResp(withRespHeader("Cache-Control", "public, max-age=120")),
+					wantStored: 1,
+					wantReq:    newReq(withReqHeader("Cache-Control", "no-cache")),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Transaction-Id", "1")),
+				},
+			},
+		},
+		{
+			name: "request no-cache ignored",
+			txs: []transaction{
+				{
+					req: newReq(),
+					resp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Etag", `"my tag"`)),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:     newReq(withReqHeader("Cache-Control", "no-cache")),
+					wantReq: newReq(withReqHeader("Cache-Control", "no-cache")),
+					wantResp: newResp(
+						withRespHeader("Age", "60"),
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:00:00 GMT"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "0")),
+				},
+			},
+		},
+		{
+			name: "request max-age=0 validates fresh cached response",
+			txs: []transaction{
+				{
+					req: newReq(),
+					resp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Etag", `"my tag"`)),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:        newReq(withReqHeader("Cache-Control", "max-age=0")),
+					resp:       newResp(withRespStatus(http.StatusNotModified)),
+					wantStored: 1,
+					wantReq: newReq(
+						withReqHeader("Cache-Control", "max-age=0"),
+						withReqHeader("If-None-Match", `"my tag"`)),
+					wantResp: newResp(
+						withRespHeader("Age", "0"),
+						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:01:00 GMT"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "1")),
+				},
+			},
+		},
+		{
+			name:   "immutable response not validated on reload",
+			config: httpcache.Config{RespectRequestDirectiveNoCache: true},
+			txs: []transaction{
+				{
+					req: newReq(),
+					resp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120, immutable"),
+						withRespHeader("Etag", `"my tag"`)),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120, immutable"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:     newReq(withReqHeader("Cache-Control", "max-age=0")),
+					wantReq: newReq(withReqHeader("Cache-Control", "max-age=0")),
+					wantResp: newResp(
+						withRespHeader("Age", "60"),
+						withRespHeader("Cache-Control", "public, max-age=120, immutable"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:00:00 GMT"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "0")),
+				},
+			},
+		},
+		{
+			name: "immutable response not validated on forced reload with ImmutableReloadNever",
+			config: httpcache.Config{
+				ImmutableReloadPolicy:          httpcache.ImmutableReloadNever,
+				RespectRequestDirectiveNoCache: true,
+			},
+			txs: []transaction{
+				{
+					req: newReq(),
+					resp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120, immutable"),
+						withRespHeader("Etag", `"my tag"`)),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120, immutable"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:     newReq(withReqHeader("Cache-Control", "no-cache")),
+					wantReq: newReq(withReqHeader("Cache-Control", "no-cache")),
+					wantResp: newResp(
+						withRespHeader("Age", "60"),
+						withRespHeader("Cache-Control", "public, max-age=120, immutable"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:00:00 GMT"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "0")),
+				},
+			},
+		},
+		{
+			name:   "immutable response validated on forced reload",
+			config: httpcache.Config{RespectRequestDirectiveNoCache: true},
+			txs: []transaction{
+				{
+					req: newReq(),
+					resp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120, immutable"),
+						withRespHeader("Etag", `"my tag"`)),
+					wantStored: 1,
+					wantReq:    newReq(),
+					wantResp: newResp(
+						withRespHeader("Cache-Control", "public, max-age=120, immutable"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "0")),
+				},
+				{
+					req:        newReq(withReqHeader("Cache-Control", "no-cache")),
+					resp:       newResp(withRespStatus(http.StatusNotModified)),
+					wantStored: 1,
+					wantReq: newReq(
+						withReqHeader("Cache-Control", "no-cache"),
+						withReqHeader("If-None-Match", `"my tag"`)),
+					wantResp: newResp(
+						withRespHeader("Age", "0"),
+						withRespHeader("Cache-Control", "public, max-age=120, immutable"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:01:00 GMT"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", "1")),
+				},
+			},
+		},
 		{
 			name: "failed validation by etag only",
 			txs: []transaction{
 				{
-					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					req: newReq(),
 					resp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my etag"`),
 						withRespHeader("Last-Modified", `Mon, 02 Jan 2006 15:04:05 GMT`)),
 					wantStored: 1,
-					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					wantReq:    newReq(),
 					wantResp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my etag"`),
@@ -440,7 +878,6 @@ func TestClient_Do(t *testing.T) {
 				},
 				{
 					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-Modified-Since", `Mon, 02 Jan 2006 15:04:05 GMT`),
 						withReqHeader("If-None-Match", `"my etag"`)),
 					resp: newResp(
@@ -449,7 +886,6 @@ func TestClient_Do(t *testing.T) {
 						withRespHeader("Last-Modified", `Mon, 02 Jan 2006 15:04:05 GMT`)),
 					wantStored: 1,
 					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-Modified-Since", `Mon, 02 Jan 2006 15:04:05 GMT`),
 						withReqHeader("If-None-Match", `"my etag"`)),
 					wantResp: newResp(
@@ -464,15 +900,13 @@ func TestClient_Do(t *testing.T) {
 			name: "failed validation by last-modified only",
 			txs: []transaction{
 				{
-					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					req: newReq(),
 					resp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my etag"`),
 						withRespHeader("Last-Modified", `Mon, 02 Jan 2006 15:04:05 GMT`)),
 					wantStored: 1,
-					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					wantReq:    newReq(),
 					wantResp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my etag"`),
@@ -481,7 +915,6 @@ func TestClient_Do(t *testing.T) {
 				},
 				{
 					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-Modified-Since", `Mon, 02 Jan 2006 15:04:05 GMT`),
 						withReqHeader("If-None-Match", `"my etag"`)),
 					resp: newResp(
@@ -490,7 +923,6 @@ func TestClient_Do(t *testing.T) {
 						withRespHeader("Last-Modified", `Mon, 03 Jan 2006 15:04:05 GMT`)),
 					wantStored: 1,
 					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-Modified-Since", `Mon, 02 Jan 2006 15:04:05 GMT`),
 						withReqHeader("If-None-Match", `"my etag"`)),
 					wantResp: newResp(
@@ -505,15 +937,13 @@ func TestClient_Do(t *testing.T) {
 			name: "failed validation by etag and last-modified",
 			txs: []transaction{
 				{
-					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					req: newReq(),
 					resp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my etag"`),
 						withRespHeader("Last-Modified", `Mon, 02 Jan 2006 15:04:05 GMT`)),
 					wantStored: 1,
-					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					wantReq:    newReq(),
 					wantResp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my etag"`),
@@ -522,7 +952,6 @@ func TestClient_Do(t *testing.T) {
 				},
 				{
 					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-Modified-Since", `Mon, 02 Jan 2006 15:04:05 GMT`),
 						withReqHeader("If-None-Match", `"my etag"`)),
 					resp: newResp(
@@ -531,7 +960,6 @@ func TestClient_Do(t *testing.T) {
 						withRespHeader("Last-Modified", `Mon, 03 Jan 2006 15:04:05 GMT`)),
 					wantStored: 1,
 					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-Modified-Since", `Mon, 02 Jan 2006 15:04:05 GMT`),
 						withReqHeader("If-None-Match", `"my etag"`)),
 					wantResp: newResp(
@@ -565,6 +993,7 @@ func TestClient_Do(t *testing.T) {
 					wantResp: newResp(
 						withRespHeader("Age", "60"),
 						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:01:00 GMT"),
 						withRespHeader("Transaction-Id", "1")),
 				},
 			},
@@ -606,6 +1035,7 @@ func TestClient_Do(t *testing.T) {
 					wantResp: newResp(
 						withRespHeader("Age", "60"),
 						withRespHeader("Cache-Control", "public, max-age=120"),
+						withRespHeader("Date", "Sat, 01 Jan 2000 00:00:00 GMT"),
 						withRespHeader("Transaction-Id", "0")),
 				},
 			},
@@ -671,14 +1101,12 @@ func TestClient_Do(t *testing.T) {
 			name: "request error on validation",
 			txs: []transaction{
 				{
-					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					req: newReq(),
 					resp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my tag"`)),
 					wantStored: 1,
-					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30")),
+					wantReq:    newReq(),
 					wantResp: newResp(
 						withRespHeader("Cache-Control", "public, max-age=60"),
 						withRespHeader("Etag", `"my tag"`),
@@ -686,11 +1114,9 @@ func TestClient_Do(t *testing.T) {
 				},
 				{
 					req: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-None-Match", `"my tag"`)),
 					respErr: errors.New("test error"),
 					wantReq: newReq(
-						withReqHeader("Cache-Control", "max-stale=30"),
 						withReqHeader("If-None-Match", `"my tag"`)),
 					wantRespErr: true,
 				},
@@ -813,128 +1239,270 @@ func TestClient_Do(t *testing.T) {
 	}
 }
 
-func TestMemoryStore(t *testing.T) {
-	tests := []struct {
-		name       string
-		storedReq  *http.Request
-		storedResp *http.Response
-		fetchedReq *http.Request
-		wantResp   *http.Response
-	}{
-		{
-			name:      "match",
-			storedReq: newReq(),
-			storedResp: newResp(
-				withRespHeader("Transaction-Id", "0")),
-			fetchedReq: newReq(),
-			wantResp: newResp(
-				withRespHeader("Age", "60"),
-				withRespHeader("Transaction-Id", "0")),
-		},
-		{
-			name:      "mismatched method",
-			storedReq: newReq(),
-			storedResp: newResp(
-				withRespHeader("Transaction-Id", "0")),
-			fetchedReq: newReq(withReqMethod("HEAD")),
-		},
-		{
-			name:      "mismatched url",
-			storedReq: newReq(),
-			storedResp: newResp(
-				withRespHeader("Transaction-Id", "0")),
-			fetchedReq: newReq(withReqUrl("https://example.com/")),
-		},
-		{
-			name: "vary match",
-			storedReq: newReq(
-				withReqHeader("Header-1", "Value-1"),
-				withReqHeader("Header-2", "Value-2"),
-				withReqHeader("Header-3", "Value-3")),
-			storedResp: newResp(
-				withRespHeader("Transaction-Id", "0"),
-				withRespHeader("Vary", "Header-1, Header-2")),
-			fetchedReq: newReq(
-				withReqHeader("Header-1", "Value-1"),
-				withReqHeader("Header-2", "Value-2"),
-				withReqHeader("Header-3", "Changed value")),
-			wantResp: newResp(
-				withRespHeader("Age", "60"),
-				withRespHeader("Transaction-Id", "0"),
-				withRespHeader("Vary", "Header-1, Header-2")),
-		},
-		{
-			name: "vary mismatch",
-			storedReq: newReq(
-				withReqHeader("Header-1", "Value-1"),
-				withReqHeader("Header-2", "Changed value"),
-				withReqHeader("Header-3", "Value-3")),
-			storedResp: newResp(
-				withRespHeader("Transaction-Id", "0"),
-				withRespHeader("Vary", "Header-1, Header-2")),
-			fetchedReq: newReq(
-				withReqHeader("Header-1", "Value-1"),
-				withReqHeader("Header-2", "Value-2"),
-				withReqHeader("Header-3", "Value-3")),
-		},
-		{
-			name: "vary wildcard",
-			storedReq: newReq(
-				withReqHeader("Header-1", "Value-1"),
-				withReqHeader("Header-2", "Value-2"),
-				withReqHeader("Header-3", "Value-3")),
-			storedResp: newResp(
-				withRespHeader("Transaction-Id", "0"),
-				withRespHeader("Vary", "*")),
-			fetchedReq: newReq(
-				withReqHeader("Header-1", "Value-1"),
-				withReqHeader("Header-2", "Value-2"),
-				withReqHeader("Header-3", "Value-3")),
-		},
-		{
-			name:      "expired",
-			storedReq: newReq(),
-			storedResp: newResp(
-				withRespHeader("Cache-Control", "public, max-age=30"),
-				withRespHeader("Transaction-Id", "0")),
-			fetchedReq: newReq(),
-			wantResp: newResp(
-				withRespHeader("Age", "60"),
-				withRespHeader("Cache-Control", "public, max-age=30"),
-				withRespHeader("Transaction-Id", "0")),
+func TestClient_Do_Clock(t *testing.T) {
+	clock := httpcache.NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var requests int
+
+	client := &httpcache.Client{
+		Config: httpcache.Config{Clock: clock},
+		Store:  &httpcache.MemoryStore{Clock: clock},
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				requests++
+
+				resp := newResp(withRespHeader("Cache-Control", "max-age=60"))
+				resp.Request = req
+				return resp, nil
+			}),
 		},
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			synctest.Test(t, func(t *testing.T) {
-				s := httpcache.NewMemoryStore()
 
-				if err := s.Set(t.Context(), tt.storedReq, time.Now(), tt.storedResp, time.Now()); err != nil {
-					t.Fatalf("Set() error = %v, want nil", err)
-				}
+	do := func() *http.Response {
+		t.Helper()
+
+		resp, err := client.Do(newReq())
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		return resp
+	}
 
-				time.Sleep(time.Minute)
+	_ = do()
 
-				gotResp, gotErr := s.Get(t.Context(), tt.fetchedReq)
+	if got, want := do().Header.Get("Date"), "Sat, 01 Jan 2000 00:00:00 GMT"; got != want {
+		t.Errorf("Do() Response.Header[Date] = %q, want %q", got, want)
+	}
 
-				if gotErr != nil {
-					t.Fatalf("Get() error = %v, want nil", gotErr)
-				}
+	clock.Advance(59 * time.Second)
 
-				if (tt.wantResp == nil) != (gotResp == nil) {
-					t.Fatalf("Get() got resp = %#v, want %#v", gotResp, tt.wantResp)
-				}
+	if got, want := do().Header.Get("Age"), "59"; got != want {
+		t.Errorf("Do() Response.Header[Age] = %q, want %q", got, want)
+	}
+
+	if got, want := requests, 1; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
 
-				if tt.wantResp != nil {
-					if got, want := gotResp.StatusCode, tt.wantResp.StatusCode; got != want {
-						t.Errorf("Get() Response.StatusCode = %d, want %d", got, want)
+	clock.Advance(time.Second)
+
+	_ = do()
+
+	if got, want := requests, 2; got != want {
+		t.Errorf("got %d requests after expiry, want %d", got, want)
+	}
+}
+
+func TestClient_Do_RevalidateStaleInBackground(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var requests []*http.Request
+
+		client := &httpcache.Client{
+			Store:                       httpcache.NewMemoryStore(),
+			RevalidateStaleInBackground: true,
+			HTTPClient: &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					requests = append(requests, req)
+
+					resp := newResp(
+						withRespHeader("Cache-Control", "max-age=30"),
+						withRespHeader("Etag", `"my tag"`),
+						withRespHeader("Transaction-Id", strconv.Itoa(len(requests))))
+					if req.Header.Get("If-None-Match") != "" {
+						resp = newResp(
+							withRespStatus(http.StatusNotModified),
+							withRespHeader("Transaction-Id", strconv.Itoa(len(requests))))
 					}
+					resp.Request = req
+					return resp, nil
+				}),
+			},
+		}
+
+		do := func(req *http.Request) *http.Response {
+			t.Helper()
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			return resp
+		}
+
+		_ = do(newReq())
+
+		time.Sleep(time.Minute)
+
+		resp := do(newReq(withReqHeader("Cache-Control", "max-stale")))
+
+		if got, want := resp.Header.Get("Transaction-Id"), "1"; got != want {
+			t.Errorf("Do() Response.Header[Transaction-Id] = %q, want %q", got, want)
+		}
+
+		synctest.Wait()
+
+		if got, want := len(requests), 2; got != want {
+			t.Fatalf("got %d requests, want %d", got, want)
+		}
+
+		if got, want := requests[1].Header.Get("If-None-Match"), `"my tag"`; got != want {
+			t.Errorf("got If-None-Match = %q, want %q", got, want)
+		}
+
+		resp = do(newReq())
 
-					if got, want := gotResp.Header, tt.wantResp.Header; want != nil && !headerEqual(got, want) {
-						t.Errorf("Get() Response.Header = %#v, want %#v", got, want)
+		if got, want := resp.Header.Get("Transaction-Id"), "2"; got != want {
+			t.Errorf("Do() Response.Header[Transaction-Id] = %q, want %q", got, want)
+		}
+
+		if got, want := resp.Header.Get("Age"), "0"; got != want {
+			t.Errorf("Do() Response.Header[Age] = %q, want %q", got, want)
+		}
+
+		if got, want := len(requests), 2; got != want {
+			t.Errorf("got %d requests after revalidation, want %d", got, want)
+		}
+	})
+}
+
+func TestClient_Do_SetCookiePolicy(t *testing.T) {
+	newClient := func(policy httpcache.SetCookiePolicy, observer httpcache.Observer) *httpcache.Client {
+		return &httpcache.Client{
+			Config:   httpcache.Config{SetCookiePolicy: policy},
+			Store:    httpcache.NewMemoryStore(),
+			Observer: observer,
+			HTTPClient: &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					if req.Header.Get("If-None-Match") != "" {
+						resp := newResp(
+							withRespStatus(http.StatusNotModified),
+							withRespHeader("Set-Cookie", "session=2"))
+						resp.Request = req
+						return resp, nil
 					}
-				}
-			})
-		})
+
+					resp := newResp(
+						withRespHeader("Cache-Control", "max-age=60"),
+						withRespHeader("Etag", `"1"`),
+						withRespHeader("Set-Cookie", "session=1"))
+					resp.Request = req
+					return resp, nil
+				}),
+			},
+		}
+	}
+
+	do := func(client *httpcache.Client, req *http.Request) *http.Response {
+		t.Helper()
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		return resp
+	}
+
+	t.Run("strip", func(t *testing.T) {
+		client := newClient(httpcache.SetCookieStrip, nil)
+
+		if got, want := do(client, newReq()).Header.Get("Set-Cookie"), "session=1"; got != want {
+			t.Errorf("got Set-Cookie %q on fetched response, want %q", got, want)
+		}
+
+		if got := do(client, newReq()).Header.Get("Set-Cookie"); got != "" {
+			t.Errorf("got Set-Cookie %q on cached response, want none", got)
+		}
+
+		// The cookie of the 304 response must not be added to the stored response either.
+		_ = do(client, newReq(withReqHeader("Cache-Control", "max-age=0")))
+
+		if got := do(client, newReq()).Header.Get("Set-Cookie"); got != "" {
+			t.Errorf("got Set-Cookie %q on revalidated response, want none", got)
+		}
+	})
+
+	t.Run("refuse", func(t *testing.T) {
+		observer := &recordingObserver{}
+
+		client := newClient(httpcache.SetCookieRefuse, observer)
+
+		if got, want := do(client, newReq()).Header.Get("Set-Cookie"), "session=1"; got != want {
+			t.Errorf("got Set-Cookie %q on fetched response, want %q", got, want)
+		}
+
+		observer.mu.Lock()
+		defer observer.mu.Unlock()
+
+		if !slices.ContainsFunc(observer.events, func(e httpcache.Event) bool {
+			return e.Type == httpcache.EventNotStored && e.Reason == httpcache.ReasonSetCookie.String()
+		}) {
+			t.Errorf("got events %v, want %s event with reason %s", observer.events, httpcache.EventNotStored,
+				httpcache.ReasonSetCookie)
+		}
+	})
+}
+
+// swappingStore calls swap on each call to Get.
+type swappingStore struct {
+	httpcache.Store
+	swap func()
+}
+
+func (s *swappingStore) Get(ctx context.Context, req *http.Request) (*http.Response, error) {
+	s.swap()
+	return s.Store.Get(ctx, req)
+}
+
+func TestClient_Do_SetConfigDuringRequest(t *testing.T) {
+	store := &trackingStore{Store: httpcache.NewMemoryStore()}
+
+	client := &httpcache.Client{
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				resp := newResp(withRespHeader("Cache-Control", "private, max-age=60"))
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	// The new configuration must only be used for following requests.
+	client.Store = &swappingStore{Store: store, swap: func() { client.SetConfig(httpcache.Config{Private: true}) }}
+
+	for _, want := range []int{0, 1} {
+		resp, err := client.Do(newReq())
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		_ = resp.Body.Close()
+
+		if got := store.stored; got != want {
+			t.Errorf("got %d stored responses, want %d", got, want)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func() httpcache.Store { return httpcache.NewMemoryStore() })
+}
+
+func TestMemoryStore_Clock(t *testing.T) {
+	clock := httpcache.NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	s := &httpcache.MemoryStore{Clock: clock}
+
+	if err := s.Set(t.Context(), newReq(), clock.Now(), newResp(), clock.Now()); err != nil {
+		t.Fatalf("Set() error = %v, want nil", err)
+	}
+
+	clock.Advance(90 * time.Second)
+
+	resp, err := s.Get(t.Context(), newReq())
+	if err != nil {
+		t.Fatalf("Get() error = %v, want nil", err)
+	}
+
+	if got, want := resp.Header.Get("Age"), "90"; got != want {
+		t.Errorf("Get() Response.Header[Age] = %q, want %q", got, want)
 	}
 }
@@ -0,0 +1,71 @@
+package httpcache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is used to get the current time.
+//
+// All age, freshness and eviction calculations in [Client] and the built-in stores use a Clock instead of calling
+// [time.Now] directly, so that they can be tested without waiting for real time to pass.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// SystemClock is a [Clock] that uses [time.Now].
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+// Now implements the [Clock] interface.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func nowFrom(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// FakeClock is a [Clock] that only changes when explicitly told to.
+//
+// This is only meant for testing.
+//
+// A FakeClock is safe for concurrent use by multiple goroutines.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a new FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Advance moves the clock forward by the given duration.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+}
+
+// Now implements the [Clock] interface.
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// Set sets the clock to the given time.
+func (f *FakeClock) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = now
+}
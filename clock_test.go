@@ -0,0 +1,30 @@
+package httpcache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nussjustin/httpcache"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	c := httpcache.NewFakeClock(start)
+
+	if got, want := c.Now(), start; !got.Equal(want) {
+		t.Errorf("Now() = %s, want %s", got, want)
+	}
+
+	c.Advance(time.Minute)
+
+	if got, want := c.Now(), start.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("Now() after Advance() = %s, want %s", got, want)
+	}
+
+	c.Set(start.Add(-time.Hour))
+
+	if got, want := c.Now(), start.Add(-time.Hour); !got.Equal(want) {
+		t.Errorf("Now() after Set() = %s, want %s", got, want)
+	}
+}
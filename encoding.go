@@ -0,0 +1,219 @@
+package httpcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// UncompressedHeader is the name of the header used to mark stored responses that were transparently decompressed by
+// the [http.Transport] before being stored, as indicated by [http.Response.Uncompressed].
+//
+// The body of such responses is decoded, but validators like the ETag header still refer to the compressed
+// representation sent by the origin. The value of the header is the removed content coding, which is always "gzip".
+//
+// The header is removed from responses returned by [Client.Do], which instead have [http.Response.Uncompressed] set.
+const UncompressedHeader = "Httpcache-Uncompressed"
+
+// transportDecompresses returns true if an [http.Transport] requests and transparently decompresses a gzip encoded
+// response for the given request, assuming compression is not disabled.
+func transportDecompresses(req *http.Request) bool {
+	return req.Method != http.MethodHead && req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == ""
+}
+
+// restoreUncompressed replaces the [UncompressedHeader] of a stored response with [http.Response.Uncompressed].
+func restoreUncompressed(resp *http.Response) {
+	if _, ok := resp.Header[UncompressedHeader]; !ok {
+		return
+	}
+
+	delete(resp.Header, UncompressedHeader)
+
+	resp.Uncompressed = true
+}
+
+// markUncompressed sets or removes the [UncompressedHeader] header of a response that is about to be stored.
+func markUncompressed(h http.Header, uncompressed bool) {
+	if uncompressed {
+		h.Set(UncompressedHeader, "gzip")
+	} else {
+		h.Del(UncompressedHeader)
+	}
+}
+
+// validators returns the validators of the stored response that can be used in a conditional request for req.
+//
+// The entity tag of a response that was decompressed by the transport belongs to the compressed representation and is
+// only used if the response to req is going to be decompressed as well, so that validators of the encoded and decoded
+// representations are never mixed.
+func validators(req *http.Request, stored *http.Response) (etag, lastModified string) {
+	etag = stored.Header.Get("Etag")
+	lastModified = stored.Header.Get("Last-Modified")
+
+	if stored.Uncompressed && !transportDecompresses(req) {
+		etag = ""
+	}
+
+	return strings.TrimPrefix(etag, "W/"), lastModified
+}
+
+// CanonicalEncodingHeader is the name of the header used to mark stored responses that use the canonical gzip encoding
+// enabled by [Client.CanonicalEncoding].
+//
+// The header is removed from responses returned by [Client.Do].
+const CanonicalEncodingHeader = "Httpcache-Canonical-Encoding"
+
+// canonicalize converts a response that is about to be stored into the canonical gzip encoded representation used by
+// [Client.CanonicalEncoding].
+//
+// Responses with the no-transform directive, partial responses and responses using another content coding are not
+// modified.
+func canonicalize(req *http.Request, resp *http.Response) error {
+	respDirectives, _ := ParseResponseDirectives(strings.Join(resp.Header["Cache-Control"], ","))
+
+	if respDirectives.NoTransform || req.Method == http.MethodHead || resp.StatusCode == http.StatusPartialContent {
+		return nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+
+		gw := gzip.NewWriter(&buf)
+		_, _ = gw.Write(body)
+		_ = gw.Close()
+
+		resp.Body = io.NopCloser(&buf)
+		resp.ContentLength = int64(buf.Len())
+		resp.Header.Set("Content-Length", strconv.Itoa(buf.Len()))
+	case "gzip", "x-gzip":
+	default:
+		return nil
+	}
+
+	canonicalizeHeader(resp.Header)
+
+	return nil
+}
+
+// canonicalizeHeader updates the headers of a response using the canonical gzip encoding.
+//
+// The entity tag is weakened, since it may have been assigned by the origin to a different representation, and
+// Accept-Encoding is removed from the Vary header, so that a single stored response is used for all encodings.
+func canonicalizeHeader(h http.Header) {
+	h.Set(CanonicalEncodingHeader, "gzip")
+	h.Set("Content-Encoding", "gzip")
+
+	if etag := h.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("Etag", "W/"+etag)
+	}
+
+	var vary []string
+
+	for _, line := range h["Vary"] {
+		for name := range strings.SplitSeq(line, ",") {
+			if name = strings.TrimSpace(name); name != "" && !strings.EqualFold(name, "Accept-Encoding") {
+				vary = append(vary, name)
+			}
+		}
+	}
+
+	if len(vary) == 0 {
+		h.Del("Vary")
+	} else {
+		h["Vary"] = []string{strings.Join(vary, ", ")}
+	}
+}
+
+// serveCanonical prepares a stored response using the canonical gzip encoding to be returned for req.
+//
+// If the request accepts gzip, the response is returned encoded. Otherwise, the body is decoded while reading and, if
+// the request has no Accept-Encoding header, [http.Response.Uncompressed] is set, the same as when the response was
+// decompressed by the [http.Transport].
+func serveCanonical(req *http.Request, resp *http.Response) {
+	if _, ok := resp.Header[CanonicalEncodingHeader]; !ok {
+		return
+	}
+
+	delete(resp.Header, CanonicalEncodingHeader)
+
+	resp.Header.Add("Vary", "Accept-Encoding")
+
+	accept := req.Header.Values("Accept-Encoding")
+
+	if len(accept) != 0 && acceptsGzip(strings.Join(accept, ",")) {
+		resp.Uncompressed = false
+		return
+	}
+
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+
+	wrapBody(resp, func(body io.ReadCloser) io.ReadCloser { return &gzipReader{body: body} })
+	resp.ContentLength = -1
+	resp.Uncompressed = len(accept) == 0
+}
+
+// acceptsGzip returns true if the given Accept-Encoding header value allows the gzip content coding.
+func acceptsGzip(accept string) bool {
+	gzipQ, wildcardQ := -1.0, -1.0
+
+	for part := range strings.SplitSeq(accept, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+
+		q := 1.0
+
+		for param := range strings.SplitSeq(params, ";") {
+			name, value, _ := strings.Cut(param, "=")
+			if strings.EqualFold(strings.TrimSpace(name), "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip", "x-gzip":
+			gzipQ = max(gzipQ, q)
+		case "*":
+			wildcardQ = max(wildcardQ, q)
+		}
+	}
+
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+
+	return wildcardQ > 0
+}
+
+// gzipReader decodes a gzip encoded body while reading.
+type gzipReader struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+func (r *gzipReader) Read(p []byte) (int, error) {
+	if r.zr == nil && r.err == nil {
+		r.zr, r.err = gzip.NewReader(r.body)
+	}
+
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	return r.zr.Read(p)
+}
+
+func (r *gzipReader) Close() error {
+	return r.body.Close()
+}
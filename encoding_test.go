@@ -0,0 +1,322 @@
+package httpcache_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nussjustin/httpcache"
+)
+
+// newGzipServer returns a server that sends a gzip encoded response with the ETag "gz" to clients accepting gzip and
+// an unencoded response with the ETag "id" to all others, without a Vary header.
+func newGzipServer(t *testing.T) (*httptest.Server, func() []string) {
+	var (
+		mu          sync.Mutex
+		ifNoneMatch []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+		mu.Unlock()
+
+		gzipped := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+		etag := `"id"`
+		if gzipped {
+			etag = `"gz"`
+		}
+
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Etag", etag)
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if !gzipped {
+			_, _ = io.WriteString(w, "hello")
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+
+		gw := gzip.NewWriter(w)
+		_, _ = io.WriteString(gw, "hello")
+		_ = gw.Close()
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		s := ifNoneMatch
+		ifNoneMatch = nil
+		return s
+	}
+}
+
+func TestClient_Do_Uncompressed(t *testing.T) {
+	server, ifNoneMatch := newGzipServer(t)
+
+	store := httpcache.NewMemoryStore()
+
+	client := &httpcache.Client{
+		Store:      store,
+		HTTPClient: server.Client(),
+	}
+
+	do := func(header ...string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatalf("NewRequest() error = %v", err)
+		}
+
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+		_ = resp.Body.Close()
+
+		if got, want := string(body), "hello"; got != want {
+			t.Errorf("got body %q, want %q", got, want)
+		}
+
+		if got := resp.Header.Get(httpcache.UncompressedHeader); got != "" {
+			t.Errorf("got %s header %q on returned response", httpcache.UncompressedHeader, got)
+		}
+
+		return resp
+	}
+
+	if resp := do(); !resp.Uncompressed {
+		t.Errorf("fetched response is not marked as uncompressed")
+	}
+
+	for e, err := range store.Entries(t.Context()) {
+		if err != nil {
+			t.Fatalf("Entries() error = %v", err)
+		}
+
+		if got, want := e.Header.Get(httpcache.UncompressedHeader), "gzip"; got != want {
+			t.Errorf("got stored %s header %q, want %q", httpcache.UncompressedHeader, got, want)
+		}
+
+		if got, want := e.Size, int64(len("hello")); got != want {
+			t.Errorf("got stored size %d, want %d", got, want)
+		}
+	}
+
+	resp := do()
+	if !resp.Uncompressed {
+		t.Errorf("cached response is not marked as uncompressed")
+	}
+
+	if got := resp.Header.Get("Content-Encoding"); got != "" {
+		t.Errorf("got Content-Encoding %q on cached response, want none", got)
+	}
+
+	if got := ifNoneMatch(); len(got) != 1 {
+		t.Errorf("got %d requests, want 1", len(got))
+	}
+
+	// The transport requests the gzip encoded representation again, so the ETag can be used.
+	if resp := do("Cache-Control", "max-age=0"); !resp.Uncompressed {
+		t.Errorf("revalidated response is not marked as uncompressed")
+	}
+
+	if got, want := ifNoneMatch(), []string{`"gz"`}; !slices.Equal(got, want) {
+		t.Errorf("got If-None-Match %q, want %q", got, want)
+	}
+
+	// The unencoded representation must not be validated using the ETag of the encoded representation.
+	if resp := do("Cache-Control", "max-age=0", "Accept-Encoding", "identity"); resp.Uncompressed {
+		t.Errorf("unencoded response is marked as uncompressed")
+	}
+
+	if got, want := ifNoneMatch(), []string{""}; !slices.Equal(got, want) {
+		t.Errorf("got If-None-Match %q, want %q", got, want)
+	}
+
+	// The stored response was replaced by the unencoded representation, which can now be validated as well.
+	if resp := do("Cache-Control", "max-age=0", "Accept-Encoding", "identity"); resp.Uncompressed {
+		t.Errorf("unencoded response is marked as uncompressed")
+	}
+
+	if got, want := ifNoneMatch(), []string{`"id"`}; !slices.Equal(got, want) {
+		t.Errorf("got If-None-Match %q, want %q", got, want)
+	}
+}
+
+func TestClient_Do_CanonicalEncoding(t *testing.T) {
+	var (
+		requests    int
+		ifNoneMatch []string
+	)
+
+	client := &httpcache.Client{
+		Store:             httpcache.NewMemoryStore(),
+		CanonicalEncoding: true,
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				requests++
+				ifNoneMatch = append(ifNoneMatch, req.Header.Get("If-None-Match"))
+
+				if req.Header.Get("If-None-Match") == `"1"` {
+					resp := newResp(
+						withRespStatus(http.StatusNotModified),
+						withRespHeader("Cache-Control", "max-age=60"),
+						withRespHeader("Etag", `"1"`),
+						withRespHeader("Vary", "Accept-Encoding"))
+					resp.Request = req
+					return resp, nil
+				}
+
+				resp := newResp(
+					withRespHeader("Cache-Control", "max-age=60"),
+					withRespHeader("Etag", `"1"`),
+					withRespHeader("Vary", "Accept-Encoding, Accept-Language"),
+					withRespBody(strings.NewReader("hello world")))
+				if req.URL.Path == "/no-transform" {
+					resp.Header.Set("Cache-Control", "max-age=60, no-transform")
+				}
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	do := func(path string, header ...string) (*http.Response, string) {
+		t.Helper()
+
+		req := newReq(withReqUrl("http://example.com" + path))
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+
+		body := resp.Body
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			if body, err = gzip.NewReader(body); err != nil {
+				t.Fatalf("failed to create gzip reader: %v", err)
+			}
+		}
+
+		b, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+		return resp, string(b)
+	}
+
+	if _, body := do("/"); body != "hello world" {
+		t.Errorf("got body %q, want %q", body, "hello world")
+	}
+
+	tests := []struct {
+		acceptEncoding   string
+		wantEncoding     string
+		wantUncompressed bool
+	}{
+		{acceptEncoding: "", wantUncompressed: true},
+		{acceptEncoding: "gzip", wantEncoding: "gzip"},
+		{acceptEncoding: "br;q=1.0, GZIP;q=0.5", wantEncoding: "gzip"},
+		{acceptEncoding: "*", wantEncoding: "gzip"},
+		{acceptEncoding: "identity"},
+		{acceptEncoding: "br"},
+		{acceptEncoding: "gzip;q=0, *"},
+	}
+	for _, tt := range tests {
+		var header []string
+		if tt.acceptEncoding != "" {
+			header = []string{"Accept-Encoding", tt.acceptEncoding}
+		}
+
+		resp, body := do("/", header...)
+
+		if body != "hello world" {
+			t.Errorf("Accept-Encoding %q: got body %q, want %q", tt.acceptEncoding, body, "hello world")
+		}
+
+		if got := resp.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+			t.Errorf("Accept-Encoding %q: got Content-Encoding %q, want %q", tt.acceptEncoding, got, tt.wantEncoding)
+		}
+
+		if got := resp.Uncompressed; got != tt.wantUncompressed {
+			t.Errorf("Accept-Encoding %q: got Uncompressed %v, want %v", tt.acceptEncoding, got, tt.wantUncompressed)
+		}
+
+		if got, want := resp.Header.Values("Vary"), []string{"Accept-Language", "Accept-Encoding"}; !slices.Equal(got, want) {
+			t.Errorf("Accept-Encoding %q: got Vary %q, want %q", tt.acceptEncoding, got, want)
+		}
+
+		if got, want := resp.Header.Get("Etag"), `W/"1"`; got != want {
+			t.Errorf("Accept-Encoding %q: got ETag %q, want %q", tt.acceptEncoding, got, want)
+		}
+
+		if got := resp.Header.Get(httpcache.CanonicalEncodingHeader); got != "" {
+			t.Errorf("Accept-Encoding %q: got %s header %q", tt.acceptEncoding, httpcache.CanonicalEncodingHeader, got)
+		}
+	}
+
+	if got, want := requests, 1; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+
+	resp, body := do("/", "Accept-Encoding", "gzip", "Cache-Control", "max-age=0")
+
+	if body != "hello world" {
+		t.Errorf("got body %q after revalidation, want %q", body, "hello world")
+	}
+
+	if got, want := resp.Header.Get("Etag"), `W/"1"`; got != want {
+		t.Errorf("got ETag %q after revalidation, want %q", got, want)
+	}
+
+	if got, want := ifNoneMatch, []string{"", `"1"`}; !slices.Equal(got, want) {
+		t.Errorf("got If-None-Match %q, want %q", got, want)
+	}
+
+	if _, body := do("/", "Accept-Encoding", "identity"); body != "hello world" {
+		t.Errorf("got body %q after revalidation, want %q", body, "hello world")
+	}
+
+	if got, want := requests, 2; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+
+	// Responses with no-transform are stored as is and therefore still vary on Accept-Encoding.
+	_, _ = do("/no-transform")
+	resp, _ = do("/no-transform", "Accept-Encoding", "gzip")
+
+	if got := resp.Header.Get("Etag"); got != `"1"` {
+		t.Errorf("got ETag %q for no-transform response, want %q", got, `"1"`)
+	}
+
+	if got, want := requests, 4; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+}
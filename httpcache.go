@@ -5,6 +5,7 @@ import (
 	"crypto/sha1"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"math"
 	"net/http"
 	"slices"
@@ -17,11 +18,36 @@ import (
 
 // Config defines characteristics of the cache based on which cacheability can be calculated.
 type Config struct {
+	// Clock is used to get the current time, e.g. when calculating the age or freshness of responses.
+	//
+	// If nil, [time.Now] is used.
+	Clock Clock
+
 	// HeuristicallyCacheableStatusCode is the list of response status codes that are considered cacheable by default.
 	//
 	// If nil, defaults to DefaultHeuristicallyCacheableStatusCodes.
 	HeuristicallyCacheableStatusCode []int
 
+	// IgnoredQueryParameters contains the names of query parameters that are ignored when looking up or storing
+	// responses, for example tracking parameters like "utm_source". A name ending in "*" matches all parameters
+	// starting with the rest of the name. The parameters are still sent to the origin.
+	//
+	// See [Config.NormalizeURL] for details.
+	IgnoredQueryParameters []string
+
+	// ImmutableReloadPolicy determines which reload requests cause a fresh response with the immutable directive to be
+	// validated.
+	//
+	// By default, only forced reloads using the no-cache directive cause validation. See [Config.ExplainReload] for
+	// details.
+	ImmutableReloadPolicy ImmutableReloadPolicy
+
+	// OverrideRules contains rules for overriding the caching information sent by origins, for example to enforce a
+	// minimum or maximum freshness lifetime.
+	//
+	// Only the first matching rule is applied. See [OverrideRule] for details.
+	OverrideRules []OverrideRule
+
 	// Private configures the cache to be private, as understood by RFC 9111.
 	Private bool
 
@@ -30,6 +56,9 @@ type Config struct {
 	//
 	// If true, the directive is checked and, if set, causes the method to return false. Otherwise, the directive is
 	// ignored.
+	//
+	// [Client] does not bypass the cache for such requests, but instead validates the stored response, if any, and
+	// updates the store with the result.
 	RespectRequestDirectiveNoCache bool
 
 	// RespectRequestDirectiveNoStore can be set to enable checking of the no-store Cache-Control request directive
@@ -48,6 +77,29 @@ type Config struct {
 	// If false, the directive is treated as if it had no value.
 	RespectResponseDirectivePrivateValue bool
 
+	// SensitiveHeaders contains the names of response headers that must never be stored, for example headers
+	// containing user specific tokens.
+	//
+	// The headers are removed by [Config.RemoveUnstorableHeaders] in both private and shared caches. This is the same
+	// as an origin listing the headers in the value of the private response directive with
+	// [Config.RespectResponseDirectivePrivateValue] set, but independent of the origin.
+	SensitiveHeaders []string
+
+	// SetCookiePolicy determines how a shared cache handles responses with a Set-Cookie header.
+	//
+	// Storing such responses causes the cookies to be sent to all users receiving the stored response. By default,
+	// responses are stored including the Set-Cookie header. See [SetCookiePolicy] for the alternatives.
+	//
+	// The policy is ignored by private caches.
+	SetCookiePolicy SetCookiePolicy
+
+	// SortQueryParameters causes the query parameters of request URLs to be sorted by name when looking up or storing
+	// responses, so that requests that only differ in the order of their query parameters share stored responses. The
+	// order of the parameters sent to the origin is not changed.
+	//
+	// See [Config.NormalizeURL] for details.
+	SortQueryParameters bool
+
 	// StoreProxyHeaders, if set, causes [Config.RemoveUnstorableHeaders] to not remove the following headers:
 	//
 	// - Proxy-Authenticate
@@ -92,13 +144,136 @@ var DefaultHeuristicallyCacheableStatusCodes = []int{
 	http.StatusNotImplemented,
 }
 
+// Reason is an enumeration of the rules that can prevent a response from being stored or reused.
+//
+// The value returned by [Reason.String] is a token that can be used in logs or e.g. as the detail parameter of a
+// Cache-Status header as defined in RFC 9211.
+type Reason uint8
+
+const (
+	// ReasonNone is the zero value of [Reason] and used when no rule failed.
+	ReasonNone Reason = iota
+
+	// ReasonUnsupportedMethod is used when the request method is not supported by the cache.
+	ReasonUnsupportedMethod
+
+	// ReasonRequestNoCache is used when the request contains the no-cache directive and
+	// [Config.RespectRequestDirectiveNoCache] is set.
+	ReasonRequestNoCache
+
+	// ReasonNonFinalStatus is used when the response status code is not final.
+	ReasonNonFinalStatus
+
+	// ReasonStatusNotUnderstood is used when the status code must be understood by the cache, either because it is 206
+	// or 304 or because of the must-understand directive, but is not.
+	ReasonStatusNotUnderstood
+
+	// ReasonNoStore is used when the response contains the no-store directive.
+	ReasonNoStore
+
+	// ReasonPrivate is used when a shared cache receives a response with the private directive.
+	ReasonPrivate
+
+	// ReasonAuthorization is used when a shared cache receives a response for a request with an Authorization header
+	// and the response does not explicitly allow shared caching.
+	ReasonAuthorization
+
+	// ReasonNoExplicitFreshness is used when the response has neither explicit freshness information nor a
+	// heuristically cacheable status code.
+	ReasonNoExplicitFreshness
+
+	// ReasonRequestNoStore is used when the request contains the no-store directive and
+	// [Config.RespectRequestDirectiveNoStore] is set.
+	ReasonRequestNoStore
+
+	// ReasonStale is used when the age of a response exceeds its freshness lifetime.
+	ReasonStale
+
+	// ReasonRequestMaxAge is used when the age of a response exceeds the max-age request directive.
+	ReasonRequestMaxAge
+
+	// ReasonRequestMinFresh is used when a response will not be fresh for as long as requested by the min-fresh request
+	// directive.
+	ReasonRequestMinFresh
+
+	// ReasonRequestPolicy is used when a [RequestPolicy] requires bypassing the cache or validating a stored response.
+	ReasonRequestPolicy
+
+	// ReasonSetCookie is used when a shared cache receives a response with a Set-Cookie header and
+	// [Config.SetCookiePolicy] is [SetCookieRefuse].
+	ReasonSetCookie
+)
+
+// String implements the [fmt.Stringer] interface.
+func (r Reason) String() string {
+	switch r {
+	case ReasonNone:
+		return "none"
+	case ReasonUnsupportedMethod:
+		return "unsupported-method"
+	case ReasonRequestNoCache:
+		return "request-no-cache"
+	case ReasonNonFinalStatus:
+		return "non-final-status"
+	case ReasonStatusNotUnderstood:
+		return "status-not-understood"
+	case ReasonNoStore:
+		return "no-store"
+	case ReasonPrivate:
+		return "private"
+	case ReasonAuthorization:
+		return "authorization"
+	case ReasonNoExplicitFreshness:
+		return "no-explicit-freshness"
+	case ReasonRequestNoStore:
+		return "request-no-store"
+	case ReasonStale:
+		return "stale"
+	case ReasonRequestMaxAge:
+		return "request-max-age"
+	case ReasonRequestMinFresh:
+		return "request-min-fresh"
+	case ReasonRequestPolicy:
+		return "request-policy"
+	case ReasonSetCookie:
+		return "set-cookie"
+	}
+
+	panic("invalid Reason")
+}
+
+// Decision is the result of checking whether a response can be stored or reused.
+type Decision struct {
+	// Allowed is true if no rule failed.
+	Allowed bool
+
+	// Reason is the first rule that failed or [ReasonNone] if Allowed is true.
+	Reason Reason
+}
+
+func allow() Decision {
+	return Decision{Allowed: true}
+}
+
+func deny(reason Reason) Decision {
+	return Decision{Reason: reason}
+}
+
 // AllowsCachedResponseFor returns true if the config allows returning a cached response for the given request.
 //
 // By default, this only checks if the request method is supported, but if [Config.RespectRequestDirectiveNoCache] is
 // true, it also checks if the request has no no-cache Cache-Control directive.
+//
+// See [Config.ExplainCachedResponseFor] for a version of this method that also returns the reason.
 func (c Config) AllowsCachedResponseFor(req *http.Request) bool {
+	return c.ExplainCachedResponseFor(req).Allowed
+}
+
+// ExplainCachedResponseFor is like [Config.AllowsCachedResponseFor], but returns a [Decision] that contains the reason
+// if no cached response may be returned.
+func (c Config) ExplainCachedResponseFor(req *http.Request) Decision {
 	if !c.isSupportedRequestMethod(req.Method) {
-		return false
+		return deny(ReasonUnsupportedMethod)
 	}
 
 	if c.RespectRequestDirectiveNoCache {
@@ -108,24 +283,139 @@ func (c Config) AllowsCachedResponseFor(req *http.Request) bool {
 		}
 
 		if reqDirectives.NoCache {
-			return false
+			return deny(ReasonRequestNoCache)
 		}
 	}
 
-	return true
+	return allow()
+}
+
+// ImmutableReloadPolicy is an enumeration of policies for handling reload requests for fresh immutable responses.
+//
+// See https://www.rfc-editor.org/rfc/rfc8246.
+type ImmutableReloadPolicy uint8
+
+const (
+	// ImmutableReloadNoCache causes fresh immutable responses to be validated for reload requests using the no-cache
+	// directive (commonly sent by browsers on a forced reload), but not for those using max-age=0.
+	//
+	// This is the default.
+	ImmutableReloadNoCache ImmutableReloadPolicy = iota
+
+	// ImmutableReloadNever causes all reload requests to be ignored for fresh immutable responses.
+	ImmutableReloadNever
+
+	// ImmutableReloadAlways causes fresh immutable responses to be validated for all reload requests, the same as all
+	// other responses.
+	ImmutableReloadAlways
+)
+
+// String implements the [fmt.Stringer] interface.
+func (p ImmutableReloadPolicy) String() string {
+	switch p {
+	case ImmutableReloadNoCache:
+		return "no-cache"
+	case ImmutableReloadNever:
+		return "never"
+	case ImmutableReloadAlways:
+		return "always"
+	}
+
+	panic("invalid ImmutableReloadPolicy")
+}
+
+// SetCookiePolicy is an enumeration of policies for handling responses with a Set-Cookie header in shared caches.
+type SetCookiePolicy uint8
+
+const (
+	// SetCookieStore causes responses to be stored including the Set-Cookie header.
+	SetCookieStore SetCookiePolicy = iota
+
+	// SetCookieStrip causes responses to be stored without the Set-Cookie header.
+	//
+	// The response returned for the request that caused the response to be stored still contains the header.
+	SetCookieStrip
+
+	// SetCookieRefuse causes responses with a Set-Cookie header to not be stored at all, unless the header is removed
+	// anyway, because it is listed in [Config.SensitiveHeaders] or in the value of the private response directive with
+	// [Config.RespectResponseDirectivePrivateValue] set.
+	//
+	// Set-Cookie headers of 304 responses used to update a stored response are removed instead.
+	SetCookieRefuse
+)
+
+// String implements the [fmt.Stringer] interface.
+func (p SetCookiePolicy) String() string {
+	switch p {
+	case SetCookieStore:
+		return "store"
+	case SetCookieStrip:
+		return "strip"
+	case SetCookieRefuse:
+		return "refuse"
+	}
+
+	panic("invalid SetCookiePolicy")
+}
+
+// ExplainReload checks if a fresh stored response with the given directives must be validated before being used for a
+// request with the given directives and returns the reason, or [ReasonNone] if the response can be used as is.
+//
+// A request asks for validation ("reload") if it contains the no-cache directive and
+// [Config.RespectRequestDirectiveNoCache] is set, in which case [ReasonRequestNoCache] is returned, or if it contains
+// the directive max-age=0, in which case [ReasonRequestMaxAge] is returned.
+//
+// For responses with the immutable directive, reloads are only honored as allowed by [Config.ImmutableReloadPolicy].
+func (c Config) ExplainReload(reqDirectives RequestDirectives, respDirectives ResponseDirectives) Reason {
+	reason := ReasonNone
+
+	switch {
+	case c.RespectRequestDirectiveNoCache && reqDirectives.NoCache:
+		reason = ReasonRequestNoCache
+	case reqDirectives.MaxAge.Valid && reqDirectives.MaxAge.Value == 0:
+		reason = ReasonRequestMaxAge
+	}
+
+	if reason == ReasonNone || !respDirectives.Immutable {
+		return reason
+	}
+
+	// From https://www.rfc-editor.org/rfc/rfc8246#section-2
+	//
+	// Clients SHOULD NOT issue a conditional request during the response's freshness lifetime (e.g., upon a reload)
+	// unless explicitly overridden by the user (e.g., a force reload).
+	switch c.ImmutableReloadPolicy {
+	case ImmutableReloadNoCache:
+		if reason != ReasonRequestNoCache {
+			return ReasonNone
+		}
+	case ImmutableReloadNever:
+		return ReasonNone
+	case ImmutableReloadAlways:
+	}
+
+	return reason
 }
 
 // AllowsStoringResponse checks if the given response can be cached.
 //
 // The response must have an associated request.
+//
+// See [Config.ExplainStoringResponse] for a version of this method that also returns the reason.
 func (c Config) AllowsStoringResponse(resp *http.Response) bool {
+	return c.ExplainStoringResponse(resp).Allowed
+}
+
+// ExplainStoringResponse is like [Config.AllowsStoringResponse], but returns a [Decision] that contains the reason if
+// the response can not be stored.
+func (c Config) ExplainStoringResponse(resp *http.Response) Decision {
 	// 3. Storing Responses in Caches
 	//
 	// A cache MUST NOT store a response to a request unless:
 
 	// - the request method is understood by the cache;
 	if !c.isSupportedRequestMethod(resp.Request.Method) {
-		return false
+		return deny(ReasonUnsupportedMethod)
 	}
 
 	var respDirectives ResponseDirectives
@@ -135,32 +425,38 @@ func (c Config) AllowsStoringResponse(resp *http.Response) bool {
 
 	// - the response status code is final (see Section 15 of [HTTP]);
 	if resp.StatusCode < 200 {
-		return false
+		return deny(ReasonNonFinalStatus)
 	}
 
 	// - if the response status code is 206 or 304, or the must-understand cache directive (see Section 5.2.2.3) is
 	//   present: the cache understands the response status code
 	if resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusNotModified || respDirectives.MustUnderstand {
 		if !c.isUnderstoodResponseCode(resp.StatusCode) {
-			return false
+			return deny(ReasonStatusNotUnderstood)
 		}
 	}
 
 	// - the no-store cache directive is not present in the response (see Section 5.2.2.5);
 	if respDirectives.NoStore {
-		return false
+		return deny(ReasonNoStore)
 	}
 
 	// - if the cache is shared: the private response directive is either not present or allows a shared cache to store
 	//   a modified response; see Section 5.2.2.7);
 	if !c.Private && respDirectives.Private && (!c.RespectResponseDirectivePrivateValue || len(respDirectives.PrivateHeaders) == 0) {
-		return false
+		return deny(ReasonPrivate)
 	}
 
 	// - if the cache is shared: the Authorization header field is not present in the request (see Section 11.6.2 of
 	//   [HTTP]) or a response directive is present that explicitly allows shared caching (see Section 3.5); and
 	if !c.Private && len(resp.Request.Header["Authorization"]) != 0 && !respDirectives.MustRevalidate && !respDirectives.Public && !respDirectives.SMaxAge.Valid {
-		return false
+		return deny(ReasonAuthorization)
+	}
+
+	// Storing the response would replay the cookies to all users of the shared cache.
+	if !c.Private && c.SetCookiePolicy == SetCookieRefuse && len(resp.Header["Set-Cookie"]) != 0 &&
+		!c.removesHeader("Set-Cookie", respDirectives) {
+		return deny(ReasonSetCookie)
 	}
 
 	// - the response contains at least one of the following
@@ -180,7 +476,7 @@ func (c Config) AllowsStoringResponse(resp *http.Response) bool {
 	// a status code that is defined as heuristically cacheable (see Section 4.2.2).
 	case c.isHeuristicallyCacheableStatusCode(resp.StatusCode):
 	default:
-		return false
+		return deny(ReasonNoExplicitFreshness)
 	}
 
 	if c.RespectRequestDirectiveNoStore {
@@ -191,11 +487,11 @@ func (c Config) AllowsStoringResponse(resp *http.Response) bool {
 
 		// Note: This is not actually part of "3. Storing Responses in Caches".
 		if reqDirectives.NoStore {
-			return false
+			return deny(ReasonRequestNoStore)
 		}
 	}
 
-	return true
+	return allow()
 }
 
 func hasValidExpires(h http.Header) bool {
@@ -214,6 +510,10 @@ func hasValidExpires(h http.Header) bool {
 	return !expires.IsZero()
 }
 
+func (c Config) now() time.Time {
+	return nowFrom(c.Clock)
+}
+
 func (c Config) isHeuristicallyCacheableStatusCode(code int) bool {
 	s := c.HeuristicallyCacheableStatusCode
 	if s == nil {
@@ -234,7 +534,23 @@ func (c Config) isUnderstoodResponseCode(code int) bool {
 	return slices.Contains(c.UnderstoodResponseCodes, code)
 }
 
+// removesHeader returns true if [Config.RemoveUnstorableHeaders] removes the named header from a response with the
+// given directives because of [Config.SensitiveHeaders] or [Config.RespectResponseDirectivePrivateValue].
+func (c Config) removesHeader(name string, respDirectives ResponseDirectives) bool {
+	equalName := func(s string) bool { return strings.EqualFold(s, name) }
+
+	if slices.ContainsFunc(c.SensitiveHeaders, equalName) {
+		return true
+	}
+
+	return c.RespectResponseDirectivePrivateValue && slices.ContainsFunc(respDirectives.PrivateHeaders, equalName)
+}
+
 // RemoveUnstorableHeaders removes response headers that must not be stored.
+//
+// In addition to the headers that must not be stored according to RFC 9111, this removes the headers listed in
+// [Config.SensitiveHeaders] and, in shared caches, the Set-Cookie header unless [Config.SetCookiePolicy] is
+// [SetCookieStore].
 func (c Config) RemoveUnstorableHeaders(headers http.Header) {
 	//  3.1. Storing Header and Trailer Fields
 	//
@@ -266,6 +582,14 @@ func (c Config) RemoveUnstorableHeaders(headers http.Header) {
 		}
 	}
 
+	for _, header := range c.SensitiveHeaders {
+		delete(headers, http.CanonicalHeaderKey(header))
+	}
+
+	if !c.Private && c.SetCookiePolicy != SetCookieStore {
+		delete(headers, "Set-Cookie")
+	}
+
 	if !c.StoreProxyHeaders {
 		// Header fields that are specific to the proxy that a cache uses when forwarding a request MUST NOT be stored,
 		// unless the cache incorporates the identity of the proxy into the cache key. Effectively, this is limited to
@@ -339,6 +663,8 @@ const (
 //
 // It does not consider directives like no-cache or must-revalidate which influence whether a (stale) response can be
 // re-used.
+//
+// See [ExplainFreshness] for a version of this function that also returns the reason.
 func CalculateFreshness(
 	currentAge time.Duration,
 	freshnessLifetime time.Duration,
@@ -346,23 +672,40 @@ func CalculateFreshness(
 	maxAge Opt[time.Duration],
 	maxStale Opt[time.Duration],
 ) Freshness {
+	freshness, _ := ExplainFreshness(currentAge, freshnessLifetime, minFresh, maxAge, maxStale)
+	return freshness
+}
+
+// ExplainFreshness is like [CalculateFreshness], but also returns the reason why the response is not fresh.
+//
+// For fresh responses the returned reason is always [ReasonNone].
+func ExplainFreshness(
+	currentAge time.Duration,
+	freshnessLifetime time.Duration,
+	minFresh Opt[time.Duration],
+	maxAge Opt[time.Duration],
+	maxStale Opt[time.Duration],
+) (Freshness, Reason) {
 	// From https://www.rfc-editor.org/rfc/rfc9111#name-freshness
 	//
 	// The calculation to determine if a response is fresh is:
-	responseIsFresh := freshnessLifetime > currentAge
+	reason := ReasonNone
+	if freshnessLifetime <= currentAge {
+		reason = ReasonStale
+	}
 
 	// Clients can send the max-age or min-fresh request directives (Section 5.2.1) to suggest limits on the freshness
 	// calculations for the corresponding response. However, caches are not required to honor them.
-	if maxAge.Valid && maxAge.Value < currentAge {
-		responseIsFresh = false
+	if reason == ReasonNone && maxAge.Valid && maxAge.Value < currentAge {
+		reason = ReasonRequestMaxAge
 	}
 
-	if minFresh.Valid && freshnessLifetime < currentAge+minFresh.Value {
-		responseIsFresh = false
+	if reason == ReasonNone && minFresh.Valid && freshnessLifetime < currentAge+minFresh.Value {
+		reason = ReasonRequestMinFresh
 	}
 
-	if responseIsFresh {
-		return FreshnessFresh
+	if reason == ReasonNone {
+		return FreshnessFresh, ReasonNone
 	}
 
 	// From https://www.rfc-editor.org/rfc/rfc9111#name-serving-stale-responses
@@ -370,14 +713,16 @@ func CalculateFreshness(
 	// A "stale" response is one that either has explicit expiry information or is allowed to have heuristic expiry
 	// calculated, but is not fresh according to the calculations in Section 4.2.
 	if maxStale.Valid && maxStale.Value >= currentAge-freshnessLifetime {
-		return FreshnessStale
+		return FreshnessStale, reason
 	}
 
-	return FreshnessExpired
+	return FreshnessExpired, reason
 }
 
 // CalculateFreshnessLifetime returns how long a response can be considered to be fresh, as defined in RFC 9111,
 // Section 4.2.
+//
+// If the response has no valid Date header, date must be set to the time the response was received.
 func CalculateFreshnessLifetime(
 	privateCache bool,
 	date time.Time,
@@ -581,13 +926,91 @@ type ExtensionDirective struct {
 }
 
 // String implements the [fmt.Stringer] interface.
+//
+// The value, if any, is formatted as token if possible and as quoted-string otherwise.
 func (e ExtensionDirective) String() string {
 	if e.Value.Valid {
-		return e.Name + `="` + e.Value.Value + `"`
+		return string(cachecontrol.AppendValue([]byte(e.Name+"="), e.Value.Value))
 	}
 	return e.Name
 }
 
+var (
+	errInvalidExtensionName  = errors.New("invalid extension directive name")
+	errInvalidExtensionValue = errors.New("invalid extension directive value")
+	errReservedExtensionName = errors.New("extension directive uses name of standard directive")
+)
+
+func (e ExtensionDirective) validate(reserved []string) error {
+	if !cachecontrol.IsToken(e.Name) {
+		return fmt.Errorf("%w: %q", errInvalidExtensionName, e.Name)
+	}
+
+	if slices.Contains(reserved, strings.ToLower(e.Name)) {
+		return fmt.Errorf("%w: %q", errReservedExtensionName, e.Name)
+	}
+
+	if e.Value.Valid && !cachecontrol.IsQuotable(e.Value.Value) {
+		return fmt.Errorf("%w: %q", errInvalidExtensionValue, e.Value.Value)
+	}
+
+	return nil
+}
+
+// formatDeltaSeconds formats the given duration as delta-seconds, rounding up to the next full second.
+//
+// Negative durations are formatted as 0.
+func formatDeltaSeconds(d time.Duration) string {
+	if d <= 0 {
+		return "0"
+	}
+
+	secs := int64(d / time.Second)
+	if d%time.Second != 0 {
+		secs++
+	}
+
+	return strconv.FormatInt(secs, 10)
+}
+
+var errNegativeDuration = errors.New("duration must not be negative")
+var errFractionalDuration = errors.New("duration must be a whole number of seconds")
+
+func validateDeltaSeconds(d Opt[time.Duration], errInvalid error) error {
+	switch {
+	case !d.Valid:
+		return nil
+	case d.Value < 0:
+		return fmt.Errorf("%w: %w: %s", errInvalid, errNegativeDuration, d.Value)
+	case d.Value%time.Second != 0 && d.Value != math.MaxInt64:
+		// math.MaxInt64 is used by ParseAge for values that overflow and is formatted as a value that overflows again.
+		return fmt.Errorf("%w: %w: %s", errInvalid, errFractionalDuration, d.Value)
+	}
+	return nil
+}
+
+var (
+	errInvalidNoCacheHeader = errors.New("invalid header name for no-cache")
+	errInvalidPrivateHeader = errors.New("invalid header name for private")
+
+	errNoCacheHeadersWithoutNoCache = errors.New("no-cache headers set without no-cache")
+	errPrivateHeadersWithoutPrivate = errors.New("private headers set without private")
+)
+
+func validateHeaderNames(names []string, errInvalid error) error {
+	for _, name := range names {
+		if !cachecontrol.IsToken(name) {
+			return fmt.Errorf("%w: %q", errInvalid, name)
+		}
+	}
+	return nil
+}
+
+func appendHeaderNames(dst []byte, names []string) []byte {
+	// Note: The quoted-string form is required, even if technically not necessary
+	return cachecontrol.AppendQuotedString(dst, strings.Join(names, " "))
+}
+
 // Opt represents a potentially unset value.
 type Opt[T any] struct {
 	// Value is the value if set or the zero value of T otherwise.
@@ -602,6 +1025,8 @@ type RequestDirectives struct {
 	// https://www.rfc-editor.org/rfc/rfc9111#name-max-age
 	MaxAge Opt[time.Duration]
 
+	// MaxStale is set to the maximum duration if the directive has no value.
+	//
 	// https://www.rfc-editor.org/rfc/rfc9111#name-max-stale
 	MaxStale Opt[time.Duration]
 
@@ -648,6 +1073,9 @@ var (
 // Invalid or conflicting values for max-age or max-stale are considered an error and the corresponding value will be
 // set to 0, which will cause any response to be considered stale, as suggested by RFC 9111, Section 4.2.1.
 //
+// A max-stale directive without value is parsed as the maximum duration, meaning that a stale response of any age is
+// acceptable.
+//
 // Similarly, an invalid or conflicting value for min-fresh will cause the value to be set to the maximum duration.
 func ParseRequestDirectives(header string) (RequestDirectives, error) {
 	var c RequestDirectives
@@ -680,7 +1108,13 @@ func ParseRequestDirectives(header string) (RequestDirectives, error) {
 
 			c.MaxAge.Value, c.MaxAge.Valid = dur, true
 		case "max-stale":
-			dur, err := ParseAge(d.Value)
+			// From https://www.rfc-editor.org/rfc/rfc9111#name-max-stale
+			//
+			// If no value is assigned to max-stale, then the client will accept a stale response of any age.
+			dur, err := time.Duration(math.MaxInt64), error(nil)
+			if d.HasValue {
+				dur, err = ParseAge(d.Value)
+			}
 			if err != nil {
 				c.MaxStale.Value, c.MaxStale.Valid = 0, true
 
@@ -748,17 +1182,63 @@ func ParseRequestDirectives(header string) (RequestDirectives, error) {
 	return c, err
 }
 
+var requestDirectiveNames = []string{
+	"max-age",
+	"max-stale",
+	"min-fresh",
+	"no-cache",
+	"no-store",
+	"no-transform",
+	"only-if-cached",
+}
+
+// Validate checks if d can be formatted using [RequestDirectives.String] so that parsing the result using
+// [ParseRequestDirectives] returns the same directives.
+//
+// Durations must not be negative and must be a whole number of seconds. Extension directives must have a valid token
+// as name that does not match any standard directive and values must not contain control characters.
+//
+// All errors are collected and returned as one using [errors.Join].
+func (d RequestDirectives) Validate() error {
+	errs := []error{
+		validateDeltaSeconds(d.MaxAge, errInvalidMaxAge),
+		validateDeltaSeconds(d.MaxStale, errInvalidMaxStale),
+		validateDeltaSeconds(d.MinFresh, errInvalidMinFresh),
+	}
+
+	for _, ext := range d.Extensions {
+		errs = append(errs, ext.validate(requestDirectiveNames))
+	}
+
+	return errors.Join(errs...)
+}
+
+// MarshalText implements the [encoding.TextMarshaler] interface.
+//
+// It returns an error if [RequestDirectives.Validate] fails.
+func (d RequestDirectives) MarshalText() ([]byte, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return []byte(d.String()), nil
+}
+
 // String implements the [fmt.Stringer] interface.
+//
+// Durations are rounded up to full seconds, with negative durations being formatted as 0. Use
+// [RequestDirectives.Validate] or [RequestDirectives.MarshalText] to detect these and other invalid values.
 func (d RequestDirectives) String() string {
 	ss := make([]string, 0, 16)
 	if d.MaxAge.Valid {
-		ss = append(ss, "max-age="+strconv.Itoa(int(d.MaxAge.Value/time.Second)))
+		ss = append(ss, "max-age="+formatDeltaSeconds(d.MaxAge.Value))
 	}
-	if d.MaxStale.Valid {
-		ss = append(ss, "max-stale="+strconv.Itoa(int(d.MaxStale.Value/time.Second)))
+	if d.MaxStale.Valid && d.MaxStale.Value == math.MaxInt64 {
+		ss = append(ss, "max-stale")
+	} else if d.MaxStale.Valid {
+		ss = append(ss, "max-stale="+formatDeltaSeconds(d.MaxStale.Value))
 	}
 	if d.MinFresh.Valid {
-		ss = append(ss, "min-fresh="+strconv.Itoa(int(d.MinFresh.Value/time.Second)))
+		ss = append(ss, "min-fresh="+formatDeltaSeconds(d.MinFresh.Value))
 	}
 	if d.NoCache {
 		ss = append(ss, "no-cache")
@@ -780,6 +1260,9 @@ func (d RequestDirectives) String() string {
 
 // ResponseDirectives contains parsed cache directives from a Cache-Control header for a response.
 type ResponseDirectives struct {
+	// https://www.rfc-editor.org/rfc/rfc8246
+	Immutable bool
+
 	// https://www.rfc-editor.org/rfc/rfc9111#name-max-age-2
 	MaxAge Opt[time.Duration]
 
@@ -847,6 +1330,8 @@ func ParseResponseDirectives(header string) (ResponseDirectives, error) {
 		name := strings.ToLower(d.Name)
 
 		switch name {
+		case "immutable":
+			c.Immutable = true
 		case "max-age":
 			dur, err := ParseAge(d.Value)
 			if err != nil {
@@ -936,11 +1421,72 @@ func ParseResponseDirectives(header string) (ResponseDirectives, error) {
 	return c, err
 }
 
+var responseDirectiveNames = []string{
+	"immutable",
+	"max-age",
+	"must-revalidate",
+	"must-understand",
+	"no-cache",
+	"no-store",
+	"no-transform",
+	"private",
+	"proxy-revalidate",
+	"public",
+	"s-maxage",
+}
+
+// Validate checks if d can be formatted using [ResponseDirectives.String] so that parsing the result using
+// [ParseResponseDirectives] returns the same directives.
+//
+// Durations must not be negative and must be a whole number of seconds. Header names for no-cache and private must be
+// valid tokens and may only be set together with the corresponding directive. Extension directives must have a valid
+// token as name that does not match any standard directive and values must not contain control characters.
+//
+// All errors are collected and returned as one using [errors.Join].
+func (d ResponseDirectives) Validate() error {
+	errs := []error{
+		validateDeltaSeconds(d.MaxAge, errInvalidMaxAge),
+		validateDeltaSeconds(d.SMaxAge, errInvalidSMaxAge),
+		validateHeaderNames(d.NoCacheHeaders, errInvalidNoCacheHeader),
+		validateHeaderNames(d.PrivateHeaders, errInvalidPrivateHeader),
+	}
+
+	if !d.NoCache && d.NoCacheHeaders != nil {
+		errs = append(errs, errNoCacheHeadersWithoutNoCache)
+	}
+
+	if !d.Private && d.PrivateHeaders != nil {
+		errs = append(errs, errPrivateHeadersWithoutPrivate)
+	}
+
+	for _, ext := range d.Extensions {
+		errs = append(errs, ext.validate(responseDirectiveNames))
+	}
+
+	return errors.Join(errs...)
+}
+
+// MarshalText implements the [encoding.TextMarshaler] interface.
+//
+// It returns an error if [ResponseDirectives.Validate] fails.
+func (d ResponseDirectives) MarshalText() ([]byte, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return []byte(d.String()), nil
+}
+
 // String implements the [fmt.Stringer] interface.
+//
+// Durations are rounded up to full seconds, with negative durations being formatted as 0. Use
+// [ResponseDirectives.Validate] or [ResponseDirectives.MarshalText] to detect these and other invalid values.
 func (d ResponseDirectives) String() string {
 	ss := make([]string, 0, 16)
+	if d.Immutable {
+		ss = append(ss, "immutable")
+	}
 	if d.MaxAge.Valid {
-		ss = append(ss, "max-age="+strconv.Itoa(int(d.MaxAge.Value/time.Second)))
+		ss = append(ss, "max-age="+formatDeltaSeconds(d.MaxAge.Value))
 	}
 	if d.MustRevalidate {
 		ss = append(ss, "must-revalidate")
@@ -949,9 +1495,8 @@ func (d ResponseDirectives) String() string {
 		ss = append(ss, "must-understand")
 	}
 	if d.NoCache {
-		if len(d.NoCacheHeaders) > 0 {
-			// Note: The quoted-string form is required, even if technically not necessary
-			ss = append(ss, `no-cache="`+strings.Join(d.NoCacheHeaders, ` `)+`"`)
+		if d.NoCacheHeaders != nil {
+			ss = append(ss, string(appendHeaderNames([]byte("no-cache="), d.NoCacheHeaders)))
 		} else {
 			ss = append(ss, "no-cache")
 		}
@@ -963,9 +1508,8 @@ func (d ResponseDirectives) String() string {
 		ss = append(ss, "no-transform")
 	}
 	if d.Private {
-		if len(d.PrivateHeaders) > 0 {
-			// Note: The quoted-string form is required, even if technically not necessary
-			ss = append(ss, `private="`+strings.Join(d.PrivateHeaders, ` `)+`"`)
+		if d.PrivateHeaders != nil {
+			ss = append(ss, string(appendHeaderNames([]byte("private="), d.PrivateHeaders)))
 		} else {
 			ss = append(ss, "private")
 		}
@@ -977,7 +1521,7 @@ func (d ResponseDirectives) String() string {
 		ss = append(ss, "public")
 	}
 	if d.SMaxAge.Valid {
-		ss = append(ss, "s-maxage="+strconv.Itoa(int(d.SMaxAge.Value/time.Second)))
+		ss = append(ss, "s-maxage="+formatDeltaSeconds(d.SMaxAge.Value))
 	}
 	for _, ext := range d.Extensions {
 		ss = append(ss, ext.String())
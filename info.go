@@ -0,0 +1,254 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StoredAtHeader is the name of the header used to pass the time at which a response was stored to and from a [Store].
+//
+// When storing a response, [Client] sets this header to the current time in the format used by [time.RFC3339Nano]. The
+// header is removed from all responses returned by [Client.Do] and can instead be retrieved using [Info].
+const StoredAtHeader = "Httpcache-Stored-At"
+
+// ResponseSource is an enumeration of the possible sources of a response returned by [Client.Do].
+type ResponseSource uint8
+
+const (
+	// SourceOrigin is used for responses received from the origin.
+	SourceOrigin ResponseSource = iota
+
+	// SourceCache is used for responses returned from the [Store], including stored responses that were validated by
+	// the origin.
+	SourceCache
+)
+
+// String implements the [fmt.Stringer] interface.
+func (s ResponseSource) String() string {
+	switch s {
+	case SourceOrigin:
+		return "origin"
+	case SourceCache:
+		return "cache"
+	}
+
+	panic("invalid ResponseSource")
+}
+
+// Revalidation is an enumeration of the outcomes of validating a stored response with the origin.
+type Revalidation uint8
+
+const (
+	// RevalidationNone is used when no stored response was validated.
+	RevalidationNone Revalidation = iota
+
+	// RevalidationNotModified is used when the origin confirmed that the stored response is still valid using a 304
+	// response.
+	RevalidationNotModified
+
+	// RevalidationModified is used when the origin sent a new response in place of the stored response.
+	RevalidationModified
+)
+
+// String implements the [fmt.Stringer] interface.
+func (r Revalidation) String() string {
+	switch r {
+	case RevalidationNone:
+		return "none"
+	case RevalidationNotModified:
+		return "not-modified"
+	case RevalidationModified:
+		return "modified"
+	}
+
+	panic("invalid Revalidation")
+}
+
+// ResponseInfo describes where a response returned by [Client.Do] came from.
+type ResponseInfo struct {
+	// Source is the source of the response.
+	Source ResponseSource
+
+	// Revalidation is the outcome of validating the stored response for the request, if any.
+	Revalidation Revalidation
+
+	// Stale is true if the response is a stale response returned from the cache without validation, e.g. because of
+	// the max-stale request directive.
+	Stale bool
+
+	// StoredAt is the time at which the response was stored or last updated in the [Store].
+	//
+	// It is the zero time for responses that were not stored or for stored responses without a valid [StoredAtHeader].
+	StoredAt time.Time
+
+	// Age is the age of the response at the time it was returned.
+	Age time.Duration
+
+	// FreshnessLifetime is the freshness lifetime of the response, taking into account the TTL of the [RequestPolicy]
+	// for the request, if any.
+	FreshnessLifetime time.Duration
+
+	// TTL is the remaining time for which the response is fresh, or zero if the response is stale.
+	TTL time.Duration
+
+	// VaryKey is the secondary cache key of the response, calculated from the request headers listed in the Vary
+	// header of the response using [Vary.Key].
+	VaryKey string
+}
+
+// infoBody is the body of a response returned by [Client.Do]. It carries the [ResponseInfo] of the response.
+type infoBody struct {
+	io.ReadCloser
+
+	// info contains the fields of the [ResponseInfo] that are known when the response is returned. The remaining
+	// fields are only calculated once [Info] is called.
+	info ResponseInfo
+
+	complete func() ResponseInfo
+}
+
+// Info returns information about the source of the given response, which must have been returned by [Client.Do].
+//
+// The information is attached to the Body of the response and is not available if the Body is replaced. Info returns
+// false for responses not returned by [Client.Do] as well as for responses created by the [Client] itself, for example
+// for requests with the only-if-cached directive that can not be served from the cache.
+//
+// The information is calculated on the first call for a response, using the headers of the response at the time it
+// was returned.
+func Info(resp *http.Response) (ResponseInfo, bool) {
+	b, ok := resp.Body.(*infoBody)
+	if !ok {
+		return ResponseInfo{}, false
+	}
+	return b.complete(), true
+}
+
+// knownInfo is like [Info], but only returns the fields that do not need to be calculated, that is all fields except
+// Age, FreshnessLifetime and TTL.
+func knownInfo(resp *http.Response) (ResponseInfo, bool) {
+	b, ok := resp.Body.(*infoBody)
+	if !ok {
+		return ResponseInfo{}, false
+	}
+	return b.info, true
+}
+
+// wrapBody replaces the body of the response with the result of calling f with the current body, keeping the
+// [ResponseInfo] of the response.
+func wrapBody(resp *http.Response, f func(body io.ReadCloser) io.ReadCloser) {
+	if b, ok := resp.Body.(*infoBody); ok {
+		b.ReadCloser = f(b.ReadCloser)
+		return
+	}
+
+	resp.Body = f(resp.Body)
+}
+
+// setInfo associates the given info with the response. The rest of the info is calculated from the request and
+// response once [Info] is called.
+func (c *Client) setInfo(config *Config, req *http.Request, resp *http.Response, info ResponseInfo, age time.Duration) {
+	// The response is returned to the caller who may modify its headers, so only the values needed for the info are
+	// kept. The Vary key depends on the request headers, which may be modified as well, and is only calculated here if
+	// it is needed.
+	cacheControl := resp.Header["Cache-Control"]
+	date := resp.Header.Get("Date")
+	expires := resp.Header.Get("Expires")
+	now := config.now()
+
+	if vary := resp.Header["Vary"]; len(vary) != 0 {
+		info.VaryKey = string(ParseVary(vary).Key(nil, req.Header))
+	}
+
+	policy, _ := RequestPolicyFromContext(req.Context())
+
+	complete := sync.OnceValue(func() ResponseInfo {
+		respDirectives, _ := ParseResponseDirectives(strings.Join(cacheControl, ","))
+
+		date, err := http.ParseTime(date)
+		if err != nil {
+			date = now.Add(-age)
+		}
+
+		var expiresAt time.Time
+		if expires != "" {
+			expiresAt, _ = ParseExpires(expires)
+		}
+
+		lifetime, _ := CalculateFreshnessLifetime(config.Private, date, expiresAt, respDirectives.MaxAge,
+			respDirectives.SMaxAge)
+
+		if policy.TTL.Valid {
+			lifetime = policy.TTL.Value
+		}
+
+		info.Age = age
+		info.FreshnessLifetime = lifetime
+		info.TTL = max(lifetime-age, 0)
+
+		return info
+	})
+
+	if b, ok := resp.Body.(*infoBody); ok {
+		b.info, b.complete = info, complete
+		return
+	}
+
+	body := resp.Body
+	if body == nil {
+		body = http.NoBody
+	}
+
+	resp.Body = &infoBody{ReadCloser: body, info: info, complete: complete}
+}
+
+// setOriginInfo is like setInfo, but calculates the age of a response received from the origin.
+func (c *Client) setOriginInfo(
+	config *Config,
+	req *http.Request, reqTime time.Time,
+	resp *http.Response, respTime time.Time,
+	info ResponseInfo,
+) {
+	var respAge Opt[time.Duration]
+	if s := resp.Header.Get("Age"); s != "" {
+		var err error
+		respAge.Value, err = ParseAge(s)
+		respAge.Valid = err == nil
+	}
+
+	respDate, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		respDate = respTime
+	}
+
+	info.Source = SourceOrigin
+
+	c.setInfo(config, req, resp, info, CalculateAge(respTime, reqTime, respAge, respDate, config.now()))
+}
+
+// setStoredInfo is like setInfo, but uses the age and [StoredAtHeader] of a response returned from the [Store].
+//
+// The [StoredAtHeader] header is removed from the response.
+func (c *Client) setStoredInfo(config *Config, req *http.Request, resp *http.Response, info ResponseInfo) {
+	var age time.Duration
+	if s := resp.Header.Get("Age"); s != "" {
+		age, _ = ParseAge(s)
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, resp.Header.Get(StoredAtHeader)); err == nil {
+		info.StoredAt = t
+	}
+
+	delete(resp.Header, StoredAtHeader)
+
+	info.Source = SourceCache
+
+	c.setInfo(config, req, resp, info, age)
+}
+
+// markStored sets the [StoredAtHeader] header to the given time.
+func markStored(h http.Header, t time.Time) {
+	h.Set(StoredAtHeader, t.UTC().Format(time.RFC3339Nano))
+}
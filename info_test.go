@@ -0,0 +1,232 @@
+package httpcache_test
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/nussjustin/httpcache"
+)
+
+func TestInfo(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	clock := httpcache.NewFakeClock(start)
+
+	var modified bool
+
+	connState := &tls.ConnectionState{ServerName: "example.com"}
+
+	client := &httpcache.Client{
+		Config: httpcache.Config{Clock: clock},
+		Store:  &httpcache.MemoryStore{Clock: clock},
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				status := http.StatusOK
+				if req.Header.Get("If-None-Match") != "" && !modified {
+					status = http.StatusNotModified
+				}
+
+				resp := newResp(
+					withRespStatus(status),
+					withRespHeader("Cache-Control", "max-age=60"),
+					withRespHeader("Date", clock.Now().Format(http.TimeFormat)),
+					withRespHeader("Etag", `"1"`),
+					withRespHeader("Vary", "Accept"))
+				resp.Request = req
+				resp.TLS = connState
+				return resp, nil
+			}),
+		},
+	}
+
+	varyKey := string(httpcache.ParseVary([]string{"Accept"}).Key(nil, http.Header{"Accept": {"text/plain"}}))
+
+	do := func(want httpcache.ResponseInfo, opts ...reqOpt) {
+		t.Helper()
+
+		req := newReq(append([]reqOpt{withReqHeader("Accept", "text/plain")}, opts...)...)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+
+		// The info must not be affected by changes made after the response was returned.
+		resp.Header.Set("Cache-Control", "max-age=3600")
+		req.Header.Set("Accept", "text/html")
+
+		got, ok := httpcache.Info(resp)
+		if !ok {
+			t.Fatalf("Info() returned false")
+		}
+
+		want.VaryKey = varyKey
+
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Info() mismatch (-want +got):\n%s", diff)
+		}
+
+		if got.Source == httpcache.SourceCache && resp.Request != req {
+			t.Errorf("got Request %p on cached response, want %p", resp.Request, req)
+		}
+
+		wantTLS := got.Source == httpcache.SourceOrigin || got.Revalidation == httpcache.RevalidationNotModified
+
+		if (resp.TLS != nil) != wantTLS {
+			t.Errorf("got TLS %v on response, want connection state = %v", resp.TLS, wantTLS)
+		}
+
+		if got := resp.Header.Get(httpcache.StoredAtHeader); got != "" {
+			t.Errorf("got %s header %q on returned response", httpcache.StoredAtHeader, got)
+		}
+	}
+
+	do(httpcache.ResponseInfo{
+		Source:            httpcache.SourceOrigin,
+		StoredAt:          start,
+		FreshnessLifetime: time.Minute,
+		TTL:               time.Minute,
+	})
+
+	clock.Advance(10 * time.Second)
+
+	do(httpcache.ResponseInfo{
+		Source:            httpcache.SourceCache,
+		StoredAt:          start,
+		Age:               10 * time.Second,
+		FreshnessLifetime: time.Minute,
+		TTL:               50 * time.Second,
+	})
+
+	do(httpcache.ResponseInfo{
+		Source:            httpcache.SourceCache,
+		StoredAt:          start,
+		Age:               10 * time.Second,
+		FreshnessLifetime: 30 * time.Second,
+		TTL:               20 * time.Second,
+	}, withRequestPolicy(httpcache.RequestPolicy{TTL: OptValue(30 * time.Second)}))
+
+	clock.Advance(time.Minute)
+
+	do(httpcache.ResponseInfo{
+		Source:            httpcache.SourceCache,
+		Stale:             true,
+		StoredAt:          start,
+		Age:               70 * time.Second,
+		FreshnessLifetime: time.Minute,
+	}, withReqHeader("Cache-Control", "max-stale"))
+
+	do(httpcache.ResponseInfo{
+		Source:            httpcache.SourceCache,
+		Revalidation:      httpcache.RevalidationNotModified,
+		StoredAt:          start.Add(70 * time.Second),
+		FreshnessLifetime: time.Minute,
+		TTL:               time.Minute,
+	})
+
+	clock.Advance(time.Minute)
+
+	modified = true
+
+	do(httpcache.ResponseInfo{
+		Source:            httpcache.SourceOrigin,
+		Revalidation:      httpcache.RevalidationModified,
+		StoredAt:          start.Add(130 * time.Second),
+		FreshnessLifetime: time.Minute,
+		TTL:               time.Minute,
+	})
+
+	do(httpcache.ResponseInfo{
+		Source:            httpcache.SourceOrigin,
+		FreshnessLifetime: time.Minute,
+		TTL:               time.Minute,
+	}, withReqMethod(http.MethodPost))
+
+	req := newReq(
+		withReqUrl("http://example.com/other"),
+		withReqHeader("Cache-Control", "only-if-cached"),
+		withReqHeader(httpcache.PartitionHeader, "spoofed"))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if resp.StatusCode != http.StatusGatewayTimeout || resp.Request != req {
+		t.Errorf("got status %d and Request %p for only-if-cached request, want %d and %p",
+			resp.StatusCode, resp.Request, http.StatusGatewayTimeout, req)
+	}
+
+	if _, ok := httpcache.Info(newResp()); ok {
+		t.Errorf("Info() for unknown response returned true")
+	}
+}
+
+func TestInfo_CanonicalEncoding(t *testing.T) {
+	client := &httpcache.Client{
+		Store:             httpcache.NewMemoryStore(),
+		CanonicalEncoding: true,
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				resp := newResp(
+					withRespHeader("Cache-Control", "max-age=60"),
+					withRespBody(strings.NewReader("hello world")))
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	for _, want := range []httpcache.ResponseSource{httpcache.SourceOrigin, httpcache.SourceCache} {
+		resp, err := client.Do(newReq())
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+
+		if got := string(body); got != "hello world" {
+			t.Errorf("got body %q, want %q", got, "hello world")
+		}
+
+		info, ok := httpcache.Info(resp)
+		if !ok {
+			t.Fatalf("Info() returned false")
+		}
+
+		if info.Source != want {
+			t.Errorf("got source %s, want %s", info.Source, want)
+		}
+	}
+}
+
+func withRequestPolicy(policy httpcache.RequestPolicy) reqOpt {
+	return func(req *http.Request) {
+		*req = *req.WithContext(httpcache.WithRequestPolicy(req.Context(), policy))
+	}
+}
+
+func TestResponseSource_String(t *testing.T) {
+	for s := httpcache.SourceOrigin; s <= httpcache.SourceCache; s++ {
+		if got := s.String(); got == "" || strings.ContainsAny(got, " \t\",;=") {
+			t.Errorf("ResponseSource(%d).String() = %q, want non-empty token", s, got)
+		}
+	}
+}
+
+func TestRevalidation_String(t *testing.T) {
+	for r := httpcache.RevalidationNone; r <= httpcache.RevalidationModified; r++ {
+		if got := r.String(); got == "" || strings.ContainsAny(got, " \t\",;=") {
+			t.Errorf("Revalidation(%d).String() = %q, want non-empty token", r, got)
+		}
+	}
+}
@@ -286,3 +286,69 @@ func Tokenize(s string) iter.Seq[Token] {
 func isControlCharacterOrSpace(c byte) bool {
 	return c <= ' ' || c == 127
 }
+
+// IsToken reports whether s is a valid token as defined in RFC 9110, Section 5.6.2.
+func IsToken(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if !isTokenChar(s[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isTokenChar(c byte) bool {
+	// tchar = "!" / "#" / "$" / "%" / "&" / "'" / "*" / "+" / "-" / "." / "^" / "_" / "`" / "|" / "~" / DIGIT / ALPHA
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) != -1
+}
+
+// IsQuotable reports whether s can be represented as a quoted-string as defined in RFC 9110, Section 5.6.4.
+//
+// This is the case as long as s does not contain any control characters other than horizontal tab.
+func IsQuotable(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < ' ' && c != '\t') || c == 127 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AppendQuotedString appends s to dst as a quoted-string, escaping all quotes and backslashes.
+//
+// s must be quotable as reported by [IsQuotable].
+func AppendQuotedString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == '"' || c == '\\' {
+			dst = append(dst, '\\')
+		}
+
+		dst = append(dst, s[i])
+	}
+
+	return append(dst, '"')
+}
+
+// AppendValue appends s to dst either as token, if s is a valid token, or as a quoted-string otherwise.
+//
+// s must be quotable as reported by [IsQuotable].
+func AppendValue(dst []byte, s string) []byte {
+	if IsToken(s) {
+		return append(dst, s...)
+	}
+
+	return AppendQuotedString(dst, s)
+}
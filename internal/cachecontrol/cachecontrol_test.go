@@ -475,3 +475,81 @@ func TestTokenize(t *testing.T) {
 		})
 	}
 }
+
+func TestIsToken(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{in: ``, want: false},
+		{in: `max-age`, want: true},
+		{in: "!#$%&'*+-.^_`|~09azAZ", want: true},
+		{in: `with space`, want: false},
+		{in: `"quoted"`, want: false},
+		{in: `a=b`, want: false},
+		{in: `a,b`, want: false},
+		{in: "tab\t", want: false},
+		{in: "\x80", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := cachecontrol.IsToken(tt.in); got != tt.want {
+				t.Errorf("IsToken(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsQuotable(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{in: ``, want: true},
+		{in: `with space`, want: true},
+		{in: `"quoted" \ back`, want: true},
+		{in: "tab\t", want: true},
+		{in: "\x80", want: true},
+		{in: "new\nline", want: false},
+		{in: "del\x7f", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := cachecontrol.IsQuotable(tt.in); got != tt.want {
+				t.Errorf("IsQuotable(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: ``, want: `""`},
+		{in: `token`, want: `token`},
+		{in: `with space`, want: `"with space"`},
+		{in: `a,b`, want: `"a,b"`},
+		{in: `say "hi"`, want: `"say \"hi\""`},
+		{in: `back\slash`, want: `"back\\slash"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got := string(cachecontrol.AppendValue(nil, tt.in))
+
+			if got != tt.want {
+				t.Errorf("AppendValue(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+
+			var tokens []cachecontrol.Token
+			for token := range cachecontrol.Tokenize(got) {
+				tokens = append(tokens, token)
+			}
+
+			if len(tokens) != 1 || tokens[0].Text != tt.in {
+				t.Errorf("Tokenize(%s) = %#v, want single text token %q", got, tokens, tt.in)
+			}
+		})
+	}
+}
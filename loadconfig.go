@@ -0,0 +1,496 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nussjustin/httpcache/internal/cachecontrol"
+)
+
+// ConfigSchema is the JSON Schema describing the configuration format accepted by [LoadConfig].
+//
+//go:embed config.schema.json
+var ConfigSchema string
+
+// ConfigError describes an invalid field in a JSON configuration loaded using [LoadConfig].
+type ConfigError struct {
+	// Field is the path to the invalid field, for example "rules[1].minTTL".
+	//
+	// Field is empty for errors that are not specific to a field, for example for syntax errors.
+	Field string
+
+	// Err describes the problem.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ConfigError) Error() string {
+	if e.Field == "" {
+		return "invalid config: " + e.Err.Error()
+	}
+	return "invalid config: " + e.Field + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+type jsonConfig struct {
+	Mode                              string               `json:"mode"`
+	SupportedMethods                  []string             `json:"supportedMethods"`
+	HeuristicallyCacheableStatusCodes []int                `json:"heuristicallyCacheableStatusCodes"`
+	UnderstoodStatusCodes             []int                `json:"understoodStatusCodes"`
+	ImmutableReload                   string               `json:"immutableReload"`
+	RespectRequestNoCache             bool                 `json:"respectRequestNoCache"`
+	RespectRequestNoStore             bool                 `json:"respectRequestNoStore"`
+	RespectPrivateValue               bool                 `json:"respectPrivateValue"`
+	SensitiveHeaders                  []string             `json:"sensitiveHeaders"`
+	SetCookie                         string               `json:"setCookie"`
+	StoreProxyHeaders                 bool                 `json:"storeProxyHeaders"`
+	KeyNormalization                  jsonKeyNormalization `json:"keyNormalization"`
+	Rules                             []jsonRule           `json:"rules"`
+}
+
+type jsonKeyNormalization struct {
+	IgnoreQueryParameters []string `json:"ignoreQueryParameters"`
+	SortQueryParameters   bool     `json:"sortQueryParameters"`
+}
+
+type jsonRule struct {
+	Host             string   `json:"host"`
+	Path             string   `json:"path"`
+	Methods          []string `json:"methods"`
+	StatusCodes      []int    `json:"statusCodes"`
+	IgnoreDirectives []string `json:"ignoreDirectives"`
+	IgnoreHeaders    []string `json:"ignoreHeaders"`
+	MinTTL           string   `json:"minTTL"`
+	MaxTTL           string   `json:"maxTTL"`
+	ForceStore       bool     `json:"forceStore"`
+}
+
+// LoadConfig reads a JSON configuration from r and returns the resulting [Config].
+//
+// The format is described by the JSON Schema in [ConfigSchema]. The configuration is a JSON object with the following
+// fields, all of which are optional:
+//
+//	{
+//	  "mode": "shared",                              // "shared" (default) or "private", see Config.Private
+//	  "supportedMethods": ["GET", "HEAD"],           // see Config.SupportedRequestMethods
+//	  "heuristicallyCacheableStatusCodes": [200],    // see Config.HeuristicallyCacheableStatusCode
+//	  "understoodStatusCodes": [206],                // see Config.UnderstoodResponseCodes
+//	  "immutableReload": "no-cache",                 // see Config.ImmutableReloadPolicy and ImmutableReloadPolicy.String
+//	  "respectRequestNoCache": false,                // see Config.RespectRequestDirectiveNoCache
+//	  "respectRequestNoStore": false,                // see Config.RespectRequestDirectiveNoStore
+//	  "respectPrivateValue": false,                  // see Config.RespectResponseDirectivePrivateValue
+//	  "sensitiveHeaders": ["X-Token"],               // see Config.SensitiveHeaders
+//	  "setCookie": "store",                          // see Config.SetCookiePolicy and SetCookiePolicy.String
+//	  "storeProxyHeaders": false,                    // see Config.StoreProxyHeaders
+//	  "keyNormalization": {
+//	    "ignoreQueryParameters": ["utm_*"],          // see Config.IgnoredQueryParameters
+//	    "sortQueryParameters": false                 // see Config.SortQueryParameters
+//	  },
+//	  "rules": [                                     // see Config.OverrideRules
+//	    {
+//	      "host": "*.example.com",
+//	      "path": "/api/*",
+//	      "methods": ["GET"],
+//	      "statusCodes": [200],
+//	      "ignoreDirectives": ["no-cache"],
+//	      "ignoreHeaders": ["Set-Cookie"],
+//	      "minTTL": "1m",                            // parsed using time.ParseDuration
+//	      "maxTTL": "1h",
+//	      "forceStore": false
+//	    }
+//	  ]
+//	}
+//
+// Unknown fields are rejected. Invalid values and invalid JSON are reported using [*ConfigError], joined using
+// [errors.Join] if there is more than one. Errors reading from r are returned as is.
+func LoadConfig(r io.Reader) (Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Config{}, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	var v any
+
+	if err := dec.Decode(&v); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return Config{}, &ConfigError{Err: fmt.Errorf("invalid JSON at offset %d: %w", syntaxErr.Offset, err)}
+		}
+
+		return Config{}, &ConfigError{Err: fmt.Errorf("invalid JSON: %w", err)}
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return Config{}, &ConfigError{Err: errors.New("unexpected data after top-level value")}
+	}
+
+	if errs := unknownFields("", v, reflect.TypeFor[jsonConfig]()); len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+
+	var jc jsonConfig
+
+	if err := json.Unmarshal(data, &jc); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return Config{}, &ConfigError{
+				Field: jsonFieldPath(typeErr.Field),
+				Err:   fmt.Errorf("cannot use %s as %s", typeErr.Value, typeErr.Type),
+			}
+		}
+
+		return Config{}, &ConfigError{Err: err}
+	}
+
+	return jc.config()
+}
+
+// unknownFields returns a [*ConfigError] for each field in v, as decoded from JSON, that does not exist in the JSON
+// representation of t.
+//
+// Unlike [json.Decoder.DisallowUnknownFields], field names are matched exactly and all unknown fields are reported
+// with their full path.
+func unknownFields(field string, v any, t reflect.Type) []error {
+	var errs []error
+
+	switch v := v.(type) {
+	case map[string]any:
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+
+		fields := make(map[string]reflect.Type, t.NumField())
+		for i := range t.NumField() {
+			f := t.Field(i)
+
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			fields[name] = f.Type
+		}
+
+		names := slices.Sorted(maps.Keys(v))
+
+		for _, name := range names {
+			path := name
+			if field != "" {
+				path = field + "." + name
+			}
+
+			ft, ok := fields[name]
+			if !ok {
+				errs = append(errs, &ConfigError{Field: path, Err: errors.New("unknown field")})
+				continue
+			}
+
+			errs = append(errs, unknownFields(path, v[name], ft)...)
+		}
+	case []any:
+		if t.Kind() != reflect.Slice {
+			return nil
+		}
+
+		for i, elem := range v {
+			errs = append(errs, unknownFields(field+"["+strconv.Itoa(i)+"]", elem, t.Elem())...)
+		}
+	}
+
+	return errs
+}
+
+// jsonFieldPath converts a field path as reported by [json.UnmarshalTypeError], for example "rules.1.minTTL", into the
+// format used by [ConfigError], for example "rules[1].minTTL".
+func jsonFieldPath(field string) string {
+	var b strings.Builder
+
+	for part := range strings.SplitSeq(field, ".") {
+		switch _, err := strconv.Atoi(part); {
+		case err == nil:
+			b.WriteString("[" + part + "]")
+		case b.Len() > 0:
+			b.WriteString("." + part)
+		default:
+			b.WriteString(part)
+		}
+	}
+
+	return b.String()
+}
+
+// LoadConfigFile is like [LoadConfig], but reads the configuration from the named file.
+func LoadConfigFile(name string) (Config, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return LoadConfig(bytes.NewReader(data))
+}
+
+func (jc *jsonConfig) config() (Config, error) {
+	var errs []error
+
+	invalid := func(field string, format string, args ...any) {
+		errs = append(errs, &ConfigError{Field: field, Err: fmt.Errorf(format, args...)})
+	}
+
+	config := Config{
+		HeuristicallyCacheableStatusCode:     jc.HeuristicallyCacheableStatusCodes,
+		IgnoredQueryParameters:               jc.KeyNormalization.IgnoreQueryParameters,
+		RespectRequestDirectiveNoCache:       jc.RespectRequestNoCache,
+		RespectRequestDirectiveNoStore:       jc.RespectRequestNoStore,
+		RespectResponseDirectivePrivateValue: jc.RespectPrivateValue,
+		SensitiveHeaders:                     jc.SensitiveHeaders,
+		SortQueryParameters:                  jc.KeyNormalization.SortQueryParameters,
+		StoreProxyHeaders:                    jc.StoreProxyHeaders,
+		SupportedRequestMethods:              jc.SupportedMethods,
+		UnderstoodResponseCodes:              jc.UnderstoodStatusCodes,
+	}
+
+	switch jc.Mode {
+	case "", "shared":
+	case "private":
+		config.Private = true
+	default:
+		invalid("mode", "unknown mode %q", jc.Mode)
+	}
+
+	if jc.ImmutableReload != "" {
+		policy, ok := parseImmutableReloadPolicy(jc.ImmutableReload)
+		if !ok {
+			invalid("immutableReload", "unknown policy %q", jc.ImmutableReload)
+		}
+		config.ImmutableReloadPolicy = policy
+	}
+
+	if jc.SetCookie != "" {
+		policy, ok := parseSetCookiePolicy(jc.SetCookie)
+		if !ok {
+			invalid("setCookie", "unknown policy %q", jc.SetCookie)
+		}
+		config.SetCookiePolicy = policy
+	}
+
+	for i, name := range jc.SensitiveHeaders {
+		if !cachecontrol.IsToken(name) {
+			invalid("sensitiveHeaders["+strconv.Itoa(i)+"]", "invalid header name %q", name)
+		}
+	}
+
+	for i, name := range jc.KeyNormalization.IgnoreQueryParameters {
+		if name == "" {
+			invalid("keyNormalization.ignoreQueryParameters["+strconv.Itoa(i)+"]", "invalid parameter name %q", name)
+		}
+	}
+
+	validateMethods := func(field string, methods []string) {
+		for i, method := range methods {
+			if !cachecontrol.IsToken(method) {
+				invalid(field+"["+strconv.Itoa(i)+"]", "invalid method %q", method)
+			}
+		}
+	}
+
+	validateStatusCodes := func(field string, codes []int) {
+		for i, code := range codes {
+			if code < 100 || code > 999 {
+				invalid(field+"["+strconv.Itoa(i)+"]", "invalid status code %d", code)
+			}
+		}
+	}
+
+	parseTTL := func(field string, s string) Opt[time.Duration] {
+		if s == "" {
+			return Opt[time.Duration]{}
+		}
+
+		d, err := time.ParseDuration(s)
+		switch {
+		case err != nil:
+			invalid(field, "invalid duration %q", s)
+		case d < 0:
+			invalid(field, "duration must not be negative")
+		}
+
+		return Opt[time.Duration]{Value: d, Valid: err == nil}
+	}
+
+	validateMethods("supportedMethods", jc.SupportedMethods)
+	validateStatusCodes("heuristicallyCacheableStatusCodes", jc.HeuristicallyCacheableStatusCodes)
+	validateStatusCodes("understoodStatusCodes", jc.UnderstoodStatusCodes)
+
+	for i, jr := range jc.Rules {
+		field := "rules[" + strconv.Itoa(i) + "]"
+
+		if jr.Host != "" && !isHostPattern(jr.Host) {
+			invalid(field+".host", "invalid host pattern %q", jr.Host)
+		}
+
+		if _, err := path.Match(jr.Path, ""); err != nil {
+			invalid(field+".path", "invalid pattern %q", jr.Path)
+		}
+
+		for j, name := range jr.IgnoreDirectives {
+			if !cachecontrol.IsToken(name) {
+				invalid(field+".ignoreDirectives["+strconv.Itoa(j)+"]", "invalid directive name %q", name)
+			}
+		}
+
+		for j, name := range jr.IgnoreHeaders {
+			if !cachecontrol.IsToken(name) {
+				invalid(field+".ignoreHeaders["+strconv.Itoa(j)+"]", "invalid header name %q", name)
+			}
+		}
+
+		validateMethods(field+".methods", jr.Methods)
+		validateStatusCodes(field+".statusCodes", jr.StatusCodes)
+
+		rule := OverrideRule{
+			Host:             jr.Host,
+			Path:             jr.Path,
+			Methods:          jr.Methods,
+			StatusCodes:      jr.StatusCodes,
+			IgnoreDirectives: jr.IgnoreDirectives,
+			IgnoreHeaders:    jr.IgnoreHeaders,
+			MinTTL:           parseTTL(field+".minTTL", jr.MinTTL),
+			MaxTTL:           parseTTL(field+".maxTTL", jr.MaxTTL),
+			ForceStore:       jr.ForceStore,
+		}
+
+		if rule.MinTTL.Valid && rule.MaxTTL.Valid && rule.MinTTL.Value > rule.MaxTTL.Value {
+			invalid(field+".minTTL", "must not be greater than maxTTL")
+		}
+
+		config.OverrideRules = append(config.OverrideRules, rule)
+	}
+
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+
+	return config, nil
+}
+
+// isHostPattern returns true if s is a valid value for [OverrideRule.Host], that is a host name or IP address without
+// port, optionally prefixed with "*." to match all subdomains.
+func isHostPattern(s string) bool {
+	s = strings.TrimPrefix(s, "*.")
+
+	return s != "" && strings.Trim(s, ".") == s && !strings.ContainsFunc(s, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '.' && r != '-' && r != ':'
+	})
+}
+
+func parseImmutableReloadPolicy(s string) (ImmutableReloadPolicy, bool) {
+	for p := ImmutableReloadNoCache; p <= ImmutableReloadAlways; p++ {
+		if p.String() == s {
+			return p, true
+		}
+	}
+
+	return 0, false
+}
+
+func parseSetCookiePolicy(s string) (SetCookiePolicy, bool) {
+	for p := SetCookieStore; p <= SetCookieRefuse; p++ {
+		if p.String() == s {
+			return p, true
+		}
+	}
+
+	return 0, false
+}
+
+// ConfigReloader loads a JSON configuration file using [LoadConfigFile] and applies it to a [Client] using
+// [Client.SetConfig].
+//
+// The Clock of the current configuration of the client is kept.
+type ConfigReloader struct {
+	// Client is the client that is updated.
+	Client *Client
+
+	// Name is the name of the configuration file.
+	Name string
+
+	// Interval is the interval at which [ConfigReloader.Run] checks the file for changes.
+	//
+	// If zero, the file is checked every 10 seconds.
+	Interval time.Duration
+
+	// ErrorHandler, if set, is called by [ConfigReloader.Run] with all errors returned by [ConfigReloader.Reload].
+	ErrorHandler func(err error)
+}
+
+// Reload loads the configuration file and applies it to the client.
+//
+// If the file can not be read or contains an invalid configuration, the configuration of the client is not changed.
+func (r *ConfigReloader) Reload() error {
+	config, err := LoadConfigFile(r.Name)
+	if err != nil {
+		return err
+	}
+
+	config.Clock = r.Client.config().Clock
+
+	r.Client.SetConfig(config)
+
+	return nil
+}
+
+// Run calls [ConfigReloader.Reload] once and then again each time the modification time or size of the file changes,
+// until the given context is canceled.
+//
+// Run always returns a non-nil error, which is the error returned by [context.Cause] for ctx.
+func (r *ConfigReloader) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last os.FileInfo
+
+	for {
+		fi, err := os.Stat(r.Name)
+
+		switch {
+		case err != nil:
+			r.handleError(err)
+		case last == nil || !fi.ModTime().Equal(last.ModTime()) || fi.Size() != last.Size():
+			last = fi
+
+			r.handleError(r.Reload())
+		}
+
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *ConfigReloader) handleError(err error) {
+	if err != nil && r.ErrorHandler != nil {
+		r.ErrorHandler(err)
+	}
+}
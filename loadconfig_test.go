@@ -0,0 +1,370 @@
+package httpcache_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/nussjustin/httpcache"
+)
+
+// fullConfig uses all fields supported by LoadConfig.
+const fullConfig = `{
+	"mode": "private",
+	"supportedMethods": ["GET"],
+	"heuristicallyCacheableStatusCodes": [200, 404],
+	"understoodStatusCodes": [206],
+	"immutableReload": "never",
+	"respectRequestNoCache": true,
+	"respectRequestNoStore": true,
+	"respectPrivateValue": true,
+	"sensitiveHeaders": ["X-Token"],
+	"setCookie": "refuse",
+	"storeProxyHeaders": true,
+	"keyNormalization": {
+		"ignoreQueryParameters": ["utm_*", "fbclid"],
+		"sortQueryParameters": true
+	},
+	"rules": [
+		{
+			"host": "*.example.com",
+			"path": "/api/*",
+			"methods": ["GET"],
+			"statusCodes": [200],
+			"ignoreDirectives": ["no-cache"],
+			"ignoreHeaders": ["Set-Cookie"],
+			"minTTL": "1m",
+			"maxTTL": "1h",
+			"forceStore": true
+		},
+		{}
+	]
+}`
+
+func TestLoadConfig(t *testing.T) {
+	got, err := httpcache.LoadConfig(strings.NewReader(fullConfig))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	want := httpcache.Config{
+		HeuristicallyCacheableStatusCode: []int{200, 404},
+		IgnoredQueryParameters:           []string{"utm_*", "fbclid"},
+		ImmutableReloadPolicy:            httpcache.ImmutableReloadNever,
+		OverrideRules: []httpcache.OverrideRule{
+			{
+				Host:             "*.example.com",
+				Path:             "/api/*",
+				Methods:          []string{"GET"},
+				StatusCodes:      []int{200},
+				IgnoreDirectives: []string{"no-cache"},
+				IgnoreHeaders:    []string{"Set-Cookie"},
+				MinTTL:           OptValue(time.Minute),
+				MaxTTL:           OptValue(time.Hour),
+				ForceStore:       true,
+			},
+			{},
+		},
+		Private:                              true,
+		RespectRequestDirectiveNoCache:       true,
+		RespectRequestDirectiveNoStore:       true,
+		RespectResponseDirectivePrivateValue: true,
+		SensitiveHeaders:                     []string{"X-Token"},
+		SetCookiePolicy:                      httpcache.SetCookieRefuse,
+		SortQueryParameters:                  true,
+		StoreProxyHeaders:                    true,
+		SupportedRequestMethods:              []string{"GET"},
+		UnderstoodResponseCodes:              []int{206},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("LoadConfig() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	type schema struct {
+		Properties map[string]*schema `json:"properties"`
+		Items      *schema            `json:"items"`
+		Ref        string             `json:"$ref"`
+		Defs       map[string]*schema `json:"$defs"`
+	}
+
+	var root schema
+	if err := json.Unmarshal([]byte(httpcache.ConfigSchema), &root); err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+
+	// names returns the names of all properties in the schema and the given value, using the same path format.
+	var names func(prefix string, s *schema, v any) (fromSchema, fromValue []string)
+	names = func(prefix string, s *schema, v any) (fromSchema, fromValue []string) {
+		if ref, ok := strings.CutPrefix(s.Ref, "#/$defs/"); ok {
+			s = root.Defs[ref]
+		}
+
+		if s.Items != nil {
+			items, _ := v.([]any)
+			if len(items) == 0 {
+				items = []any{nil}
+			}
+			return names(prefix+"[]", s.Items, items[0])
+		}
+
+		m, _ := v.(map[string]any)
+
+		for name, p := range s.Properties {
+			fromSchema = append(fromSchema, prefix+name)
+
+			ss, vv := names(prefix+name+".", p, m[name])
+			fromSchema = append(fromSchema, ss...)
+			fromValue = append(fromValue, vv...)
+		}
+
+		for name := range m {
+			fromValue = append(fromValue, prefix+name)
+		}
+
+		return fromSchema, fromValue
+	}
+
+	var v any
+	if err := json.Unmarshal([]byte(fullConfig), &v); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	fromSchema, fromValue := names("", &root, v)
+
+	slices.Sort(fromSchema)
+	slices.Sort(fromValue)
+
+	if diff := cmp.Diff(fromSchema, fromValue); diff != "" {
+		t.Errorf("schema properties mismatch (-schema +config):\n%s", diff)
+	}
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		in         string
+		wantFields []string
+	}{
+		{
+			name:       "syntax",
+			in:         `{"mode":`,
+			wantFields: []string{""},
+		},
+		{
+			name:       "invalid",
+			in:         `{"mode": shared}`,
+			wantFields: []string{""},
+		},
+		{
+			name:       "unknown field",
+			in:         `{"private": true}`,
+			wantFields: []string{"private"},
+		},
+		{
+			name: "unknown nested fields",
+			in: `{
+				"Mode": "shared",
+				"keyNormalization": {"sortQuery": true},
+				"rules": [{}, {"path": "/", "minTtl": "1m", "host": "a"}]
+			}`,
+			wantFields: []string{"Mode", "keyNormalization.sortQuery", "rules[1].minTtl"},
+		},
+		{
+			name:       "trailing data",
+			in:         `{} {}`,
+			wantFields: []string{""},
+		},
+		{
+			name:       "not an object",
+			in:         `[]`,
+			wantFields: []string{""},
+		},
+		{
+			name:       "type",
+			in:         `{"rules": [{"minTTL": 60}]}`,
+			wantFields: []string{"rules[0].minTTL"},
+		},
+		{
+			name:       "nested type",
+			in:         `{"keyNormalization": {"sortQueryParameters": "yes"}}`,
+			wantFields: []string{"keyNormalization.sortQueryParameters"},
+		},
+		{
+			name: "values",
+			in: `{
+				"mode": "public",
+				"supportedMethods": ["GET", "BAD METHOD"],
+				"heuristicallyCacheableStatusCodes": [200, 42],
+				"immutableReload": "sometimes",
+				"setCookie": "never",
+				"sensitiveHeaders": ["X-Token", "Bad Header"],
+				"keyNormalization": {"ignoreQueryParameters": ["utm_*", ""]},
+				"rules": [
+					{"minTTL": "1h"},
+					{"path": "/[", "minTTL": "-1s", "maxTTL": "soon"},
+					{"minTTL": "2h", "maxTTL": "1h", "statusCodes": [1000]},
+					{"host": "*.example.com"},
+					{"host": "example.com/api", "ignoreDirectives": ["no-cache", "max age"]},
+					{"host": "*.", "ignoreHeaders": ["Set-Cookie", "Set Cookie"]}
+				]
+			}`,
+			wantFields: []string{
+				"mode",
+				"immutableReload",
+				"setCookie",
+				"sensitiveHeaders[1]",
+				"keyNormalization.ignoreQueryParameters[1]",
+				"supportedMethods[1]",
+				"heuristicallyCacheableStatusCodes[1]",
+				"rules[1].path",
+				"rules[1].minTTL",
+				"rules[1].maxTTL",
+				"rules[2].statusCodes[0]",
+				"rules[2].minTTL",
+				"rules[4].host",
+				"rules[4].ignoreDirectives[1]",
+				"rules[5].host",
+				"rules[5].ignoreHeaders[1]",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := httpcache.LoadConfig(strings.NewReader(tt.in))
+			if err == nil {
+				t.Fatal("LoadConfig() error = nil, want error")
+			}
+
+			var fields []string
+
+			errs := []error{err}
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				errs = joined.Unwrap()
+			}
+
+			for _, err := range errs {
+				var configErr *httpcache.ConfigError
+				if !errors.As(err, &configErr) {
+					t.Errorf("got error %v, want %T", err, configErr)
+					continue
+				}
+				fields = append(fields, configErr.Field)
+			}
+
+			if diff := cmp.Diff(tt.wantFields, fields); diff != "" {
+				t.Errorf("LoadConfig() error fields mismatch (-want +got):\n%s\nerror: %v", diff, err)
+			}
+		})
+	}
+}
+
+func TestConfigReloader(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "config.json")
+
+		write := func(s string) {
+			t.Helper()
+
+			if err := os.WriteFile(name, []byte(s), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+		}
+
+		observer := &recordingObserver{}
+
+		client := &httpcache.Client{
+			Store:    httpcache.NewMemoryStore(),
+			Observer: observer,
+			HTTPClient: &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					resp := newResp(withRespHeader("Cache-Control", "private, max-age=60"))
+					resp.Request = req
+					return resp, nil
+				}),
+			},
+		}
+
+		var requests int
+
+		// Responses with the private directive are only stored by private caches.
+		isPrivate := func() bool {
+			t.Helper()
+
+			requests++
+
+			if _, err := client.Do(newReq(withReqUrl("http://example.com/" + strconv.Itoa(requests)))); err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+
+			return slices.Contains(observer.types(), httpcache.EventStored)
+		}
+
+		var errs []error
+
+		reloader := &httpcache.ConfigReloader{
+			Client:       client,
+			Name:         name,
+			Interval:     time.Second,
+			ErrorHandler: func(err error) { errs = append(errs, err) },
+		}
+
+		write(`{"mode": "private"}`)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		go func() { _ = reloader.Run(ctx) }()
+
+		synctest.Wait()
+
+		if !isPrivate() {
+			t.Error("config was not loaded")
+		}
+
+		write(`{"mode": "invalid"}`)
+
+		time.Sleep(time.Second)
+		synctest.Wait()
+
+		if !isPrivate() {
+			t.Error("invalid config was applied")
+		}
+
+		if len(errs) != 1 {
+			t.Errorf("got %d errors, want 1", len(errs))
+		}
+
+		write(`{"mode": "shared", "rules": []}`)
+
+		time.Sleep(time.Second)
+		synctest.Wait()
+
+		if isPrivate() {
+			t.Error("updated config was not loaded")
+		}
+
+		cancel()
+		synctest.Wait()
+
+		if !slices.ContainsFunc(errs, func(err error) bool {
+			var configErr *httpcache.ConfigError
+			return errors.As(err, &configErr) && configErr.Field == "mode"
+		}) {
+			t.Errorf("got errors %v, want error for field mode", errs)
+		}
+	})
+}
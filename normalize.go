@@ -0,0 +1,80 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// NormalizeURL normalizes the query of the given URL as configured by [Config.IgnoredQueryParameters] and
+// [Config.SortQueryParameters].
+//
+// Ignored parameters are removed and, if enabled, the remaining parameters are sorted by name. Parameters with the
+// same name keep their relative order. The encoding of the parameters is not changed.
+//
+// [Client] normalizes the URL of requests before passing them to the [Store], so that requests that only differ in
+// their query share the same stored responses. Requests sent to the origin are not normalized.
+func (c Config) NormalizeURL(u *url.URL) {
+	if u.RawQuery == "" || (len(c.IgnoredQueryParameters) == 0 && !c.SortQueryParameters) {
+		return
+	}
+
+	params := strings.Split(u.RawQuery, "&")
+
+	params = slices.DeleteFunc(params, func(param string) bool {
+		return param == "" || c.ignoresQueryParameter(queryParameterName(param))
+	})
+
+	if c.SortQueryParameters {
+		slices.SortStableFunc(params, func(a, b string) int {
+			return strings.Compare(queryParameterName(a), queryParameterName(b))
+		})
+	}
+
+	u.RawQuery = strings.Join(params, "&")
+}
+
+// ignoresQueryParameter returns true if the named query parameter matches one of [Config.IgnoredQueryParameters].
+func (c Config) ignoresQueryParameter(name string) bool {
+	for _, ignored := range c.IgnoredQueryParameters {
+		if prefix, ok := strings.CutSuffix(ignored, "*"); ok && strings.HasPrefix(name, prefix) {
+			return true
+		}
+
+		if ignored == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// queryParameterName returns the decoded name of a single query parameter in the form "name=value".
+func queryParameterName(param string) string {
+	name, _, _ := strings.Cut(param, "=")
+
+	if unescaped, err := url.QueryUnescape(name); err == nil {
+		return unescaped
+	}
+
+	return name
+}
+
+// normalize returns the request with its URL normalized using [Config.NormalizeURL], for use with the [Store].
+//
+// If the URL is not changed, the request is returned as is. Otherwise, a copy of the request is returned.
+func normalize(config *Config, req *http.Request) *http.Request {
+	u := *req.URL
+
+	config.NormalizeURL(&u)
+
+	if u.RawQuery == req.URL.RawQuery {
+		return req
+	}
+
+	req = req.Clone(req.Context())
+	req.URL = &u
+
+	return req
+}
@@ -0,0 +1,133 @@
+package httpcache_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/nussjustin/httpcache"
+)
+
+func TestConfig_NormalizeURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		config httpcache.Config
+		in     string
+		want   string
+	}{
+		{
+			name: "disabled",
+			in:   "http://example.com/?b=2&utm_source=x&a=1",
+			want: "http://example.com/?b=2&utm_source=x&a=1",
+		},
+		{
+			name:   "ignored",
+			config: httpcache.Config{IgnoredQueryParameters: []string{"fbclid", "utm_*"}},
+			in:     "http://example.com/?b=2&utm_source=x&fbclid=y&a=1&utm_medium=z&fbclid2=1",
+			want:   "http://example.com/?b=2&a=1&fbclid2=1",
+		},
+		{
+			name:   "ignored escaped",
+			config: httpcache.Config{IgnoredQueryParameters: []string{"a b"}},
+			in:     "http://example.com/?a+b=1&a%20b=2&c=3",
+			want:   "http://example.com/?c=3",
+		},
+		{
+			name:   "ignored all",
+			config: httpcache.Config{IgnoredQueryParameters: []string{"*"}},
+			in:     "http://example.com/?a=1&b=2",
+			want:   "http://example.com/",
+		},
+		{
+			name:   "sorted",
+			config: httpcache.Config{SortQueryParameters: true},
+			in:     "http://example.com/?b=2&a=3&c&a=1&&b=%2F",
+			want:   "http://example.com/?a=3&a=1&b=2&b=%2F&c",
+		},
+		{
+			name: "ignored and sorted",
+			config: httpcache.Config{
+				IgnoredQueryParameters: []string{"utm_*"},
+				SortQueryParameters:    true,
+			},
+			in:   "http://example.com/?b=2&utm_source=x&a=1",
+			want: "http://example.com/?a=1&b=2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.in)
+			if err != nil {
+				t.Fatalf("failed to parse URL: %v", err)
+			}
+
+			tt.config.NormalizeURL(u)
+
+			if got := u.String(); got != tt.want {
+				t.Errorf("NormalizeURL(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Do_NormalizeURL(t *testing.T) {
+	var requests []string
+
+	client := &httpcache.Client{
+		Config: httpcache.Config{
+			IgnoredQueryParameters: []string{"utm_*"},
+			SortQueryParameters:    true,
+		},
+		Store: httpcache.NewMemoryStore(),
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				requests = append(requests, req.Method+" "+req.URL.String())
+
+				resp := newResp(withRespHeader("Cache-Control", "max-age=60"))
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	do := func(method, urlStr string) {
+		t.Helper()
+
+		req := newReq(withReqMethod(method), withReqUrl(urlStr))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		_ = resp.Body.Close()
+
+		if got := req.URL.String(); got != urlStr {
+			t.Errorf("request URL was modified to %q", got)
+		}
+	}
+
+	do(http.MethodGet, "http://example.com/?b=2&a=1&utm_source=x")
+	do(http.MethodGet, "http://example.com/?a=1&b=2")
+	do(http.MethodGet, "http://example.com/?utm_medium=y&b=2&a=1")
+
+	// Invalidation must use the normalized URL as well.
+	do(http.MethodPost, "http://example.com/?b=2&utm_source=z&a=1")
+	do(http.MethodGet, "http://example.com/?a=1&b=2")
+
+	want := []string{
+		"GET http://example.com/?b=2&a=1&utm_source=x",
+		"POST http://example.com/?b=2&utm_source=z&a=1",
+		"GET http://example.com/?a=1&b=2",
+	}
+
+	if diff := cmp.Diff(want, requests); diff != "" {
+		t.Errorf("requests mismatch (-want +got):\n%s", diff)
+	}
+
+	n, err := client.Delete(t.Context(), newReq(withReqUrl("http://example.com/?utm_source=x&b=2&a=1")))
+	if err != nil || n != 1 {
+		t.Errorf("Delete() = %d, %v, want 1, nil", n, err)
+	}
+}
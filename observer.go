@@ -0,0 +1,298 @@
+package httpcache
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EventType is an enumeration of the decisions and operations reported by [Client] to an [Observer].
+type EventType uint8
+
+const (
+	// EventTypeInvalid is the zero value of [EventType] and is not a valid value.
+	EventTypeInvalid EventType = iota
+
+	// EventBypass is reported when a request is sent without consulting the cache, for example because the request
+	// method is not supported.
+	EventBypass
+
+	// EventHit is reported when a fresh stored response is returned.
+	EventHit
+
+	// EventMiss is reported when no usable stored response was found.
+	EventMiss
+
+	// EventStaleServed is reported when a stale stored response is returned without validating it first.
+	EventStaleServed
+
+	// EventRevalidated is reported when a stored response was validated by the origin using a 304 response and is
+	// returned.
+	EventRevalidated
+
+	// EventStored is reported when a response was passed to the [Store].
+	EventStored
+
+	// EventNotStored is reported when a response can not be stored. [Event.Reason] contains the reason.
+	EventNotStored
+
+	// EventInvalidated is reported when one or more stored responses were removed from the cache.
+	EventInvalidated
+
+	// EventStoreGet is reported after each call to [Store.Get], with [Event.Duration] and [Event.Err] set.
+	EventStoreGet
+
+	// EventStoreSet is reported after each call to [Store.Set], with [Event.Duration] and [Event.Err] set.
+	EventStoreSet
+
+	// EventInvalidHeader is reported when a request or response header could not be parsed. [Event.Reason] contains
+	// the name of the header.
+	EventInvalidHeader
+)
+
+// String implements the [fmt.Stringer] interface.
+func (t EventType) String() string {
+	switch t {
+	case EventTypeInvalid:
+		return "invalid"
+	case EventBypass:
+		return "bypass"
+	case EventHit:
+		return "hit"
+	case EventMiss:
+		return "miss"
+	case EventStaleServed:
+		return "stale"
+	case EventRevalidated:
+		return "revalidated"
+	case EventStored:
+		return "stored"
+	case EventNotStored:
+		return "not-stored"
+	case EventInvalidated:
+		return "invalidated"
+	case EventStoreGet:
+		return "store-get"
+	case EventStoreSet:
+		return "store-set"
+	case EventInvalidHeader:
+		return "invalid-header"
+	}
+
+	panic("invalid EventType")
+}
+
+// Event describes a single decision or operation of a [Client].
+type Event struct {
+	// Type is the type of the event.
+	Type EventType
+
+	// Request is the request that caused the event.
+	Request *http.Request
+
+	// Response is the response the event refers to, if any.
+	//
+	// The response must not be modified and its body must not be read.
+	Response *http.Response
+
+	// Reason contains additional information about the event, e.g. why a response was not stored.
+	Reason string
+
+	// Duration contains the time spent on the operation, for events that describe an operation.
+	Duration time.Duration
+
+	// Err contains the error returned by the operation, if any.
+	Err error
+
+	// Policy is the [RequestPolicy] attached to the context of the request, if any.
+	Policy *RequestPolicy
+}
+
+// Observer can be used to observe the decisions made by a [Client].
+//
+// An Observer must be safe for concurrent use by multiple goroutines.
+type Observer interface {
+	// Observe is called synchronously for each event.
+	//
+	// The given context is the context of the request that caused the event.
+	Observe(ctx context.Context, e Event)
+}
+
+// ObserverFunc implements the [Observer] interface using a function.
+type ObserverFunc func(ctx context.Context, e Event)
+
+// Observe implements the [Observer] interface.
+func (f ObserverFunc) Observe(ctx context.Context, e Event) {
+	f(ctx, e)
+}
+
+// MultiObserver returns an [Observer] that passes each event to all given observers in order.
+func MultiObserver(observers ...Observer) Observer {
+	return ObserverFunc(func(ctx context.Context, e Event) {
+		for _, o := range observers {
+			o.Observe(ctx, e)
+		}
+	})
+}
+
+// NewSlogObserver returns an [Observer] that logs all events to the given logger.
+//
+// Events with an error are logged at [slog.LevelWarn], all other events at [slog.LevelDebug].
+func NewSlogObserver(logger *slog.Logger) Observer {
+	return ObserverFunc(func(ctx context.Context, e Event) {
+		level := slog.LevelDebug
+		if e.Err != nil {
+			level = slog.LevelWarn
+		}
+
+		if !logger.Enabled(ctx, level) {
+			return
+		}
+
+		attrs := make([]slog.Attr, 0, 8)
+		attrs = append(attrs, slog.String("event", e.Type.String()))
+
+		if e.Request != nil {
+			attrs = append(attrs,
+				slog.String("method", e.Request.Method),
+				slog.String("url", e.Request.URL.String()))
+		}
+
+		if e.Response != nil {
+			attrs = append(attrs, slog.Int("status", e.Response.StatusCode))
+		}
+
+		if e.Reason != "" {
+			attrs = append(attrs, slog.String("reason", e.Reason))
+		}
+
+		if e.Duration != 0 {
+			attrs = append(attrs, slog.Duration("duration", e.Duration))
+		}
+
+		if e.Policy != nil {
+			attrs = append(attrs, slog.String("policy", e.Policy.String()))
+		}
+
+		if e.Err != nil {
+			attrs = append(attrs, slog.Any("error", e.Err))
+		}
+
+		logger.LogAttrs(ctx, level, "httpcache: "+e.Type.String(), attrs...)
+	})
+}
+
+// ExpvarOptions configures [NewExpvarObserver].
+//
+// The zero value only maintains the counters for all hosts.
+type ExpvarOptions struct {
+	// Hosts, if set, is used to maintain the same counters per host of the request, using a nested map for each host.
+	//
+	// The map is not published by [NewExpvarObserver]. Use [expvar.Publish] or [expvar.Map.Set] to make it visible.
+	Hosts *expvar.Map
+
+	// MaxHosts is the maximum number of hosts added to Hosts. Events for additional hosts are counted under the key
+	// "(other)".
+	//
+	// If zero or negative, at most 100 hosts are added.
+	MaxHosts int
+}
+
+// expvarOtherHosts is the key used in [ExpvarOptions.Hosts] for hosts exceeding [ExpvarOptions.MaxHosts]. It can not
+// be a valid host.
+const expvarOtherHosts = "(other)"
+
+// NewExpvarObserver returns an [Observer] that counts events using the given map.
+//
+// For each event the counter with the name of the event type (see [EventType.String]) is incremented. Errors are
+// counted as "<event type>-errors" and durations are summed up in seconds as "<event type>-seconds".
+//
+// If [ExpvarOptions.Hosts] is set, the same counters are maintained per host.
+func NewExpvarObserver(m *expvar.Map, opts ExpvarOptions) Observer {
+	maxHosts := opts.MaxHosts
+	if maxHosts <= 0 {
+		maxHosts = 100
+	}
+
+	var (
+		hostsMu sync.Mutex
+		hosts   = make(map[string]*expvar.Map)
+	)
+
+	return ObserverFunc(func(_ context.Context, e Event) {
+		addExpvarEvent(m, e)
+
+		if opts.Hosts == nil || e.Request == nil || e.Request.URL == nil {
+			return
+		}
+
+		host := e.Request.URL.Host
+
+		hostsMu.Lock()
+		hm, ok := hosts[host]
+		if !ok {
+			if len(hosts) >= maxHosts {
+				host = expvarOtherHosts
+			}
+
+			// The map may be shared with other observers, so existing counters are reused.
+			if hm, ok = opts.Hosts.Get(host).(*expvar.Map); !ok {
+				hm = new(expvar.Map)
+				opts.Hosts.Set(host, hm)
+			}
+
+			if host != expvarOtherHosts {
+				hosts[host] = hm
+			}
+		}
+		hostsMu.Unlock()
+
+		addExpvarEvent(hm, e)
+	})
+}
+
+func addExpvarEvent(m *expvar.Map, e Event) {
+	name := e.Type.String()
+
+	m.Add(name, 1)
+
+	if e.Err != nil {
+		m.Add(name+"-errors", 1)
+	}
+
+	if e.Duration != 0 {
+		m.AddFloat(name+"-seconds", e.Duration.Seconds())
+	}
+}
+
+// Tracer is used by [Client] to create spans for requests and store operations.
+//
+// The interface is modeled after OpenTelemetry and is meant to be implemented by a small adapter, so that this package
+// does not depend on any tracing library.
+type Tracer interface {
+	// Start creates a new span as a child of any span in the given context and returns a context containing the span.
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+}
+
+// Span represents a single operation started using a [Tracer].
+type Span interface {
+	// AddEvent records an event for the span.
+	AddEvent(name string, attrs ...slog.Attr)
+
+	// RecordError records an error for the span.
+	RecordError(err error)
+
+	// End completes the span.
+	End()
+}
+
+type nopSpan struct{}
+
+func (nopSpan) AddEvent(string, ...slog.Attr) {}
+
+func (nopSpan) RecordError(error) {}
+
+func (nopSpan) End() {}
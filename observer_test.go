@@ -0,0 +1,351 @@
+package httpcache_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nussjustin/httpcache"
+)
+
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []httpcache.Event
+}
+
+func (r *recordingObserver) Observe(_ context.Context, e httpcache.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, e)
+}
+
+func (r *recordingObserver) types() []httpcache.EventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	types := make([]httpcache.EventType, len(r.events))
+	for i, e := range r.events {
+		types[i] = e.Type
+	}
+	r.events = nil
+	return types
+}
+
+func TestClient_Do_Observer(t *testing.T) {
+	clock := httpcache.NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	observer := &recordingObserver{}
+
+	store := &trackingStore{Store: &httpcache.MemoryStore{Clock: clock}}
+
+	client := &httpcache.Client{
+		Config:   httpcache.Config{Clock: clock},
+		Store:    store,
+		Observer: observer,
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				resp := newResp(
+					withRespHeader("Cache-Control", "max-age=60"),
+					withRespHeader("Etag", `"tag"`))
+				if req.Header.Get("If-None-Match") != "" {
+					resp = newResp(withRespStatus(http.StatusNotModified))
+				}
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		advance    time.Duration
+		failGet    bool
+		failSet    bool
+		want       []httpcache.EventType
+		wantReason string
+	}{
+		{
+			name: "miss",
+			req:  newReq(),
+			want: []httpcache.EventType{
+				httpcache.EventStoreGet,
+				httpcache.EventMiss,
+				httpcache.EventStoreSet,
+				httpcache.EventStored,
+			},
+		},
+		{
+			name: "hit",
+			req:  newReq(),
+			want: []httpcache.EventType{
+				httpcache.EventStoreGet,
+				httpcache.EventHit,
+			},
+		},
+		{
+			name:    "stale",
+			req:     newReq(withReqHeader("Cache-Control", "max-stale=60")),
+			advance: 90 * time.Second,
+			want: []httpcache.EventType{
+				httpcache.EventStoreGet,
+				httpcache.EventStaleServed,
+			},
+		},
+		{
+			name: "revalidated",
+			req:  newReq(),
+			want: []httpcache.EventType{
+				httpcache.EventStoreGet,
+				httpcache.EventRevalidated,
+				httpcache.EventStoreSet,
+			},
+		},
+		{
+			name: "bypass",
+			req:  newReq(withReqMethod("POST")),
+			want: []httpcache.EventType{
+				httpcache.EventBypass,
+			},
+		},
+		{
+			name: "not stored",
+			req:  newReq(withReqUrl("http://example.com/private"), withReqHeader("Authorization", "Basic dGVzdDp0ZXN0")),
+			want: []httpcache.EventType{
+				httpcache.EventStoreGet,
+				httpcache.EventMiss,
+				httpcache.EventNotStored,
+			},
+			wantReason: "authorization",
+		},
+		{
+			name:    "store errors",
+			req:     newReq(withReqUrl("http://example.com/other")),
+			failGet: true,
+			failSet: true,
+			want: []httpcache.EventType{
+				httpcache.EventStoreGet,
+				httpcache.EventMiss,
+				httpcache.EventStoreSet,
+			},
+		},
+		{
+			name: "invalid header",
+			req:  newReq(withReqUrl("http://example.com/invalid"), withReqHeader("Cache-Control", "max-age=x")),
+			want: []httpcache.EventType{
+				httpcache.EventInvalidHeader,
+				httpcache.EventStoreGet,
+				httpcache.EventMiss,
+				httpcache.EventStoreSet,
+				httpcache.EventStored,
+			},
+		},
+	}
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+
+		store.failOnGet, store.failOnStore = tt.failGet, tt.failSet
+
+		if _, err := client.Do(tt.req); err != nil {
+			t.Fatalf("%s: Do() error = %v", tt.name, err)
+		}
+
+		if tt.wantReason != "" {
+			observer.mu.Lock()
+			reason := observer.events[len(observer.events)-1].Reason
+			observer.mu.Unlock()
+
+			if reason != tt.wantReason {
+				t.Errorf("%s: got reason %q, want %q", tt.name, reason, tt.wantReason)
+			}
+		}
+
+		if got := observer.types(); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got events %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewSlogObserver(t *testing.T) {
+	var buf bytes.Buffer
+
+	o := httpcache.NewSlogObserver(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	o.Observe(t.Context(), httpcache.Event{
+		Type:    httpcache.EventNotStored,
+		Request: newReq(),
+		Reason:  "no-store",
+	})
+
+	o.Observe(t.Context(), httpcache.Event{
+		Type:    httpcache.EventStoreGet,
+		Request: newReq(),
+		Err:     errors.New("broken"),
+	})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	if got, want := len(lines), 2; got != want {
+		t.Fatalf("got %d lines, want %d:\n%s", got, want, buf.String())
+	}
+
+	for _, want := range []string{"level=DEBUG", "event=not-stored", "url=http://example.com/", "reason=no-store"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("got line %q, want %q", lines[0], want)
+		}
+	}
+
+	for _, want := range []string{"level=WARN", "event=store-get", "error=broken"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("got line %q, want %q", lines[1], want)
+		}
+	}
+}
+
+func TestNewExpvarObserver(t *testing.T) {
+	m := new(expvar.Map)
+
+	o := httpcache.NewExpvarObserver(m, httpcache.ExpvarOptions{})
+
+	o.Observe(t.Context(), httpcache.Event{Type: httpcache.EventHit, Request: newReq()})
+	o.Observe(t.Context(), httpcache.Event{Type: httpcache.EventHit, Request: newReq(withReqUrl("http://example.org/"))})
+	o.Observe(t.Context(), httpcache.Event{
+		Type:     httpcache.EventStoreGet,
+		Request:  newReq(),
+		Duration: time.Second,
+		Err:      errStoreFail,
+	})
+
+	if got, want := m.Get("hit").String(), "2"; got != want {
+		t.Errorf("hit = %s, want %s", got, want)
+	}
+
+	if got, want := m.Get("store-get-errors").String(), "1"; got != want {
+		t.Errorf("store-get-errors = %s, want %s", got, want)
+	}
+
+	if got, want := m.Get("store-get-seconds").String(), "1"; got != want {
+		t.Errorf("store-get-seconds = %s, want %s", got, want)
+	}
+
+	var keys []string
+	m.Do(func(kv expvar.KeyValue) { keys = append(keys, kv.Key) })
+
+	if want := []string{"hit", "store-get", "store-get-errors", "store-get-seconds"}; !slices.Equal(keys, want) {
+		t.Errorf("got keys %q, want %q", keys, want)
+	}
+}
+
+func TestNewExpvarObserver_Hosts(t *testing.T) {
+	m, hosts := new(expvar.Map), new(expvar.Map)
+
+	o := httpcache.NewExpvarObserver(m, httpcache.ExpvarOptions{Hosts: hosts, MaxHosts: 2})
+
+	for _, host := range []string{"example.com", "example.org", "example.com", "example.net", "example.io"} {
+		o.Observe(t.Context(), httpcache.Event{Type: httpcache.EventHit, Request: newReq(withReqUrl("http://" + host))})
+	}
+
+	if got := m.Get("hosts"); got != nil {
+		t.Errorf("got hosts %s in map, want none", got)
+	}
+
+	for host, want := range map[string]string{"example.com": "2", "example.org": "1", "(other)": "2"} {
+		hm, ok := hosts.Get(host).(*expvar.Map)
+		if !ok {
+			t.Errorf("hosts[%s] not found", host)
+			continue
+		}
+
+		if got := hm.Get("hit").String(); got != want {
+			t.Errorf("hosts[%s].hit = %s, want %s", host, got, want)
+		}
+	}
+
+	if got := hosts.Get("example.net"); got != nil {
+		t.Errorf("got hosts[example.net] = %s, want none", got)
+	}
+}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+type recordingSpan struct {
+	name   string
+	events []string
+	errs   []error
+	ended  bool
+}
+
+func (r *recordingTracer) Start(ctx context.Context, name string, _ ...slog.Attr) (context.Context, httpcache.Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &recordingSpan{name: name}
+	r.spans = append(r.spans, s)
+	return ctx, s
+}
+
+func (s *recordingSpan) AddEvent(name string, _ ...slog.Attr) {
+	s.events = append(s.events, name)
+}
+
+func (s *recordingSpan) RecordError(err error) {
+	s.errs = append(s.errs, err)
+}
+
+func (s *recordingSpan) End() {
+	s.ended = true
+}
+
+func TestClient_Do_Tracer(t *testing.T) {
+	tracer := &recordingTracer{}
+
+	client := &httpcache.Client{
+		Store:  &trackingStore{Store: httpcache.NewMemoryStore(), failOnStore: true},
+		Tracer: tracer,
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				resp := newResp(withRespHeader("Cache-Control", "max-age=60"))
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	if _, err := client.Do(newReq()); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	var names []string
+	for _, s := range tracer.spans {
+		names = append(names, s.name)
+
+		if !s.ended {
+			t.Errorf("span %s not ended", s.name)
+		}
+	}
+
+	if want := []string{"httpcache.Client.Do", "httpcache.Store.Get", "httpcache.Store.Set"}; !slices.Equal(names, want) {
+		t.Errorf("got spans %v, want %v", names, want)
+	}
+
+	wantEvents := []string{"httpcache.store-get", "httpcache.miss", "httpcache.store-set"}
+	if got := tracer.spans[0].events; !slices.Equal(got, wantEvents) {
+		t.Errorf("got span events %v, want %v", got, wantEvents)
+	}
+
+	if got := tracer.spans[2].errs; len(got) != 1 || !errors.Is(got[0], errStoreFail) {
+		t.Errorf("got span errors %v, want [%v]", got, errStoreFail)
+	}
+}
@@ -0,0 +1,228 @@
+package httpcache
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nussjustin/httpcache/internal/cachecontrol"
+)
+
+// OverrideRule overrides the caching information sent by an origin for matching responses.
+//
+// Rules are configured using [Config.OverrideRules] and are applied by the [Client] to the headers of a response before
+// checking if the response can be stored and before storing it, similar to the proxy_ignore_headers and
+// proxy_cache_valid directives of nginx. Stored responses therefore contain the modified headers.
+//
+// A rule matches a response if all of its non-empty conditions match.
+type OverrideRule struct {
+	// Host is matched against the host name of the request URL, ignoring case and without any port.
+	//
+	// A pattern starting with "*." matches all subdomains of the following domain, but not the domain itself.
+	Host string
+
+	// Path is matched against the path of the request URL using [path.Match].
+	Path string
+
+	// Methods contains the request methods matched by the rule.
+	Methods []string
+
+	// StatusCodes contains the response status codes matched by the rule.
+	StatusCodes []int
+
+	// IgnoreDirectives contains the names of Cache-Control response directives that are removed from matching
+	// responses, for example "no-cache" or "private".
+	IgnoreDirectives []string
+
+	// IgnoreHeaders contains the names of response headers that are removed from matching responses, for example
+	// "Expires", "Set-Cookie" or "Vary".
+	IgnoreHeaders []string
+
+	// MinTTL, if set, is the minimum freshness lifetime of matching responses.
+	//
+	// Responses without explicit freshness lifetime are given this lifetime.
+	MinTTL Opt[time.Duration]
+
+	// MaxTTL, if set, is the maximum freshness lifetime of matching responses.
+	MaxTTL Opt[time.Duration]
+
+	// ForceStore causes matching responses to be stored, even if they would not be stored otherwise because of the
+	// no-store or private directives, the Authorization or Set-Cookie headers, a missing explicit freshness lifetime or
+	// the no-store request directive.
+	//
+	// Responses for requests with unsupported methods as well as responses with non-final (1xx) or not understood
+	// status codes (for example 206 or 304, see [Config.UnderstoodResponseCodes]) are never stored.
+	ForceStore bool
+}
+
+// forcesStore returns true if the rule causes a response to be stored that would not be stored for the given reason.
+func (r OverrideRule) forcesStore(reason Reason) bool {
+	if !r.ForceStore {
+		return false
+	}
+
+	switch reason {
+	case ReasonNoStore,
+		ReasonPrivate,
+		ReasonAuthorization,
+		ReasonSetCookie,
+		ReasonNoExplicitFreshness,
+		ReasonRequestNoStore:
+		return true
+	default:
+		return false
+	}
+}
+
+// Matches returns true if the rule matches the given request and response status code.
+func (r OverrideRule) Matches(req *http.Request, statusCode int) bool {
+	if r.Host != "" && !matchHost(r.Host, req.URL.Hostname()) {
+		return false
+	}
+
+	if r.Path != "" {
+		if ok, _ := path.Match(r.Path, req.URL.Path); !ok {
+			return false
+		}
+	}
+
+	if len(r.Methods) != 0 && !slices.Contains(r.Methods, req.Method) {
+		return false
+	}
+
+	if len(r.StatusCodes) != 0 && !slices.Contains(r.StatusCodes, statusCode) {
+		return false
+	}
+
+	return true
+}
+
+func matchHost(pattern, host string) bool {
+	if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+		return len(host) > len(domain)+1 &&
+			strings.EqualFold(host[len(host)-len(domain):], domain) &&
+			host[len(host)-len(domain)-1] == '.'
+	}
+
+	return strings.EqualFold(pattern, host)
+}
+
+// OverrideRuleFor returns the first rule in [Config.OverrideRules] that matches the given request and response status
+// code.
+func (c Config) OverrideRuleFor(req *http.Request, statusCode int) (OverrideRule, bool) {
+	for _, rule := range c.OverrideRules {
+		if rule.Matches(req, statusCode) {
+			return rule, true
+		}
+	}
+
+	return OverrideRule{}, false
+}
+
+// ApplyOverrideRule modifies the given response headers according to the rule.
+//
+// Ignored headers and directives are removed first. If the freshness lifetime of the remaining headers, as calculated
+// by [CalculateFreshnessLifetime], is outside the limits of the rule, the max-age directive (and the s-maxage
+// directive, if present and the cache is shared) is replaced with the nearest limit.
+func (c Config) ApplyOverrideRule(rule OverrideRule, header http.Header) {
+	for _, name := range rule.IgnoreHeaders {
+		delete(header, http.CanonicalHeaderKey(name))
+	}
+
+	if len(rule.IgnoreDirectives) != 0 {
+		removeDirectives(header, func(name string) bool {
+			return slices.ContainsFunc(rule.IgnoreDirectives, func(s string) bool { return strings.EqualFold(s, name) })
+		})
+	}
+
+	if !rule.MinTTL.Valid && !rule.MaxTTL.Valid {
+		return
+	}
+
+	respDirectives, _ := ParseResponseDirectives(strings.Join(header["Cache-Control"], ","))
+
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		date = c.now()
+	}
+
+	var expires time.Time
+	if s := header.Get("Expires"); s != "" {
+		expires, _ = ParseExpires(s)
+	}
+
+	lifetime, ok := CalculateFreshnessLifetime(c.Private, date, expires, respDirectives.MaxAge, respDirectives.SMaxAge)
+
+	var clamped time.Duration
+
+	switch {
+	case rule.MinTTL.Valid && (!ok || lifetime < rule.MinTTL.Value):
+		clamped = rule.MinTTL.Value
+	case rule.MaxTTL.Valid && ok && lifetime > rule.MaxTTL.Value:
+		clamped = rule.MaxTTL.Value
+	default:
+		return
+	}
+
+	replaceSMaxAge := !c.Private && respDirectives.SMaxAge.Valid
+
+	removeDirectives(header, func(name string) bool {
+		return strings.EqualFold(name, "max-age") || (replaceSMaxAge && strings.EqualFold(name, "s-maxage"))
+	})
+
+	seconds := formatDeltaSeconds(clamped)
+
+	directives := []string{"max-age=" + seconds}
+
+	if replaceSMaxAge {
+		directives = append(directives, "s-maxage="+seconds)
+	}
+
+	// removeDirectives leaves at most a single line.
+	if lines := header["Cache-Control"]; len(lines) == 1 {
+		directives = append([]string{lines[0]}, directives...)
+	}
+
+	header["Cache-Control"] = []string{strings.Join(directives, ", ")}
+}
+
+// removeDirectives removes all Cache-Control directives for which drop returns true from the given header.
+func removeDirectives(header http.Header, drop func(name string) bool) {
+	lines := header["Cache-Control"]
+	if len(lines) == 0 {
+		return
+	}
+
+	var b []byte
+
+	for d := range cachecontrol.Parse(strings.Join(lines, ",")) {
+		if drop(d.Name) {
+			continue
+		}
+
+		if len(b) > 0 {
+			b = append(b, ", "...)
+		}
+
+		b = append(b, d.Name...)
+
+		switch {
+		case !d.HasValue:
+		case cachecontrol.IsQuotable(d.Value):
+			b = append(b, '=')
+			b = cachecontrol.AppendValue(b, d.Value)
+		default:
+			b = append(b, '=')
+			b = append(b, d.Value...)
+		}
+	}
+
+	if len(b) == 0 {
+		delete(header, "Cache-Control")
+		return
+	}
+
+	header["Cache-Control"] = []string{string(b)}
+}
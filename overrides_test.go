@@ -0,0 +1,392 @@
+package httpcache_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/nussjustin/httpcache"
+)
+
+func TestOverrideRule_Matches(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   httpcache.OverrideRule
+		req    *http.Request
+		status int
+		want   bool
+	}{
+		{
+			name:   "empty",
+			req:    newReq(),
+			status: http.StatusOK,
+			want:   true,
+		},
+		{
+			name:   "host",
+			rule:   httpcache.OverrideRule{Host: "EXAMPLE.com"},
+			req:    newReq(withReqUrl("http://example.com:8080/")),
+			status: http.StatusOK,
+			want:   true,
+		},
+		{
+			name:   "host mismatch",
+			rule:   httpcache.OverrideRule{Host: "example.org"},
+			req:    newReq(),
+			status: http.StatusOK,
+		},
+		{
+			name:   "host wildcard",
+			rule:   httpcache.OverrideRule{Host: "*.example.com"},
+			req:    newReq(withReqUrl("http://api.example.com/")),
+			status: http.StatusOK,
+			want:   true,
+		},
+		{
+			name:   "host wildcard does not match domain",
+			rule:   httpcache.OverrideRule{Host: "*.example.com"},
+			req:    newReq(),
+			status: http.StatusOK,
+		},
+		{
+			name:   "host wildcard does not match suffix",
+			rule:   httpcache.OverrideRule{Host: "*.example.com"},
+			req:    newReq(withReqUrl("http://badexample.com/")),
+			status: http.StatusOK,
+		},
+		{
+			name:   "path",
+			rule:   httpcache.OverrideRule{Path: "/api/*/items"},
+			req:    newReq(withReqUrl("http://example.com/api/v1/items")),
+			status: http.StatusOK,
+			want:   true,
+		},
+		{
+			name:   "path mismatch",
+			rule:   httpcache.OverrideRule{Path: "/api/*"},
+			req:    newReq(withReqUrl("http://example.com/api/v1/items")),
+			status: http.StatusOK,
+		},
+		{
+			name:   "method",
+			rule:   httpcache.OverrideRule{Methods: []string{"HEAD", "GET"}},
+			req:    newReq(),
+			status: http.StatusOK,
+			want:   true,
+		},
+		{
+			name:   "method mismatch",
+			rule:   httpcache.OverrideRule{Methods: []string{"HEAD"}},
+			req:    newReq(),
+			status: http.StatusOK,
+		},
+		{
+			name:   "status",
+			rule:   httpcache.OverrideRule{StatusCodes: []int{http.StatusOK}},
+			req:    newReq(),
+			status: http.StatusOK,
+			want:   true,
+		},
+		{
+			name:   "status mismatch",
+			rule:   httpcache.OverrideRule{StatusCodes: []int{http.StatusOK}},
+			req:    newReq(),
+			status: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Matches(tt.req, tt.status); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_OverrideRuleFor(t *testing.T) {
+	config := httpcache.Config{
+		OverrideRules: []httpcache.OverrideRule{
+			{Path: "/a", ForceStore: true},
+			{Path: "/*", MinTTL: OptValue(time.Minute)},
+			{Path: "/b", MaxTTL: OptValue(time.Minute)},
+		},
+	}
+
+	tests := []struct {
+		url    string
+		want   httpcache.OverrideRule
+		wantOk bool
+	}{
+		{url: "http://example.com/a", want: config.OverrideRules[0], wantOk: true},
+		{url: "http://example.com/b", want: config.OverrideRules[1], wantOk: true},
+		{url: "http://example.com/c/d"},
+	}
+	for _, tt := range tests {
+		got, ok := config.OverrideRuleFor(newReq(withReqUrl(tt.url)), http.StatusOK)
+
+		if diff := cmp.Diff(tt.want, got); diff != "" || ok != tt.wantOk {
+			t.Errorf("OverrideRuleFor(%q) = %v (-want +got):\n%s", tt.url, ok, diff)
+		}
+	}
+}
+
+func TestConfig_ApplyOverrideRule(t *testing.T) {
+	date := "Sat, 01 Jan 2000 00:00:00 GMT"
+
+	tests := []struct {
+		name    string
+		private bool
+		rule    httpcache.OverrideRule
+		header  http.Header
+		want    http.Header
+	}{
+		{
+			name:   "empty",
+			header: http.Header{"Cache-Control": {"no-cache, max-age=0"}},
+			want:   http.Header{"Cache-Control": {"no-cache, max-age=0"}},
+		},
+		{
+			name:   "ignore headers",
+			rule:   httpcache.OverrideRule{IgnoreHeaders: []string{"set-cookie", "Vary"}},
+			header: http.Header{"Set-Cookie": {"a=b"}, "Vary": {"Accept"}, "Etag": {`"x"`}},
+			want:   http.Header{"Etag": {`"x"`}},
+		},
+		{
+			name:   "ignore directives",
+			rule:   httpcache.OverrideRule{IgnoreDirectives: []string{"No-Cache", "private"}},
+			header: http.Header{"Cache-Control": {`private="Set-Cookie", no-cache`, `max-age=60, ext="a b"`}},
+			want:   http.Header{"Cache-Control": {`max-age=60, ext="a b"`}},
+		},
+		{
+			name:   "ignore all directives",
+			rule:   httpcache.OverrideRule{IgnoreDirectives: []string{"no-store"}},
+			header: http.Header{"Cache-Control": {"no-store"}},
+			want:   http.Header{},
+		},
+		{
+			name: "min ttl",
+			rule: httpcache.OverrideRule{
+				IgnoreDirectives: []string{"no-cache"},
+				MinTTL:           OptValue(time.Hour),
+			},
+			header: http.Header{"Cache-Control": {"no-cache, max-age=0"}},
+			want:   http.Header{"Cache-Control": {"max-age=3600"}},
+		},
+		{
+			name:   "min ttl without freshness information",
+			rule:   httpcache.OverrideRule{MinTTL: OptValue(time.Hour)},
+			header: http.Header{"Etag": {`"x"`}},
+			want:   http.Header{"Etag": {`"x"`}, "Cache-Control": {"max-age=3600"}},
+		},
+		{
+			name:   "min ttl not reached",
+			rule:   httpcache.OverrideRule{MinTTL: OptValue(time.Hour)},
+			header: http.Header{"Cache-Control": {"public, max-age=7200"}},
+			want:   http.Header{"Cache-Control": {"public, max-age=7200"}},
+		},
+		{
+			name:   "min ttl below one second",
+			rule:   httpcache.OverrideRule{MinTTL: OptValue(500 * time.Millisecond)},
+			header: http.Header{"Cache-Control": {"max-age=0"}},
+			want:   http.Header{"Cache-Control": {"max-age=1"}},
+		},
+		{
+			name:   "max ttl with fractional seconds",
+			rule:   httpcache.OverrideRule{MaxTTL: OptValue(1500 * time.Millisecond)},
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			want:   http.Header{"Cache-Control": {"max-age=2"}},
+		},
+		{
+			name:   "max ttl",
+			rule:   httpcache.OverrideRule{MaxTTL: OptValue(time.Hour)},
+			header: http.Header{"Cache-Control": {"public, max-age=31536000, immutable"}},
+			want:   http.Header{"Cache-Control": {"public, immutable, max-age=3600"}},
+		},
+		{
+			name:   "max ttl with expires",
+			rule:   httpcache.OverrideRule{MaxTTL: OptValue(time.Hour)},
+			header: http.Header{"Date": {date}, "Expires": {"Mon, 01 Jan 2001 00:00:00 GMT"}},
+			want: http.Header{
+				"Date":          {date},
+				"Expires":       {"Mon, 01 Jan 2001 00:00:00 GMT"},
+				"Cache-Control": {"max-age=3600"},
+			},
+		},
+		{
+			name:   "max ttl without freshness information",
+			rule:   httpcache.OverrideRule{MaxTTL: OptValue(time.Hour)},
+			header: http.Header{"Etag": {`"x"`}},
+			want:   http.Header{"Etag": {`"x"`}},
+		},
+		{
+			name:   "max ttl with s-maxage",
+			rule:   httpcache.OverrideRule{MaxTTL: OptValue(time.Hour)},
+			header: http.Header{"Cache-Control": {"max-age=60, s-maxage=86400"}},
+			want:   http.Header{"Cache-Control": {"max-age=3600, s-maxage=3600"}},
+		},
+		{
+			name:    "max ttl with s-maxage in private cache",
+			private: true,
+			rule:    httpcache.OverrideRule{MaxTTL: OptValue(time.Hour)},
+			header:  http.Header{"Cache-Control": {"max-age=86400, s-maxage=60"}},
+			want:    http.Header{"Cache-Control": {"s-maxage=60, max-age=3600"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := httpcache.Config{Private: tt.private}
+			config.ApplyOverrideRule(tt.rule, tt.header)
+
+			if diff := cmp.Diff(tt.want, tt.header); diff != "" {
+				t.Errorf("ApplyOverrideRule() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClient_Do_OverrideRules(t *testing.T) {
+	clock := httpcache.NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var requests int
+
+	client := &httpcache.Client{
+		Config: httpcache.Config{
+			Clock: clock,
+			OverrideRules: []httpcache.OverrideRule{
+				{
+					Path:             "/hourly",
+					IgnoreDirectives: []string{"no-cache"},
+					MinTTL:           OptValue(time.Hour),
+				},
+				{
+					Path:          "/forced",
+					IgnoreHeaders: []string{"Set-Cookie"},
+					MinTTL:        OptValue(time.Minute),
+					ForceStore:    true,
+				},
+			},
+		},
+		Store: &httpcache.MemoryStore{Clock: clock},
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				requests++
+
+				resp := newResp(
+					withRespHeader("Cache-Control", "no-cache, max-age=0"),
+					withRespHeader("Set-Cookie", "session=1"))
+				if req.URL.Path == "/forced" {
+					resp.Header.Set("Cache-Control", "no-store")
+				}
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	do := func(url string) *http.Response {
+		t.Helper()
+
+		resp, err := client.Do(newReq(withReqUrl(url)))
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		return resp
+	}
+
+	first := do("http://example.com/hourly")
+
+	if got, want := first.Header.Get("Cache-Control"), "no-cache, max-age=0"; got != want {
+		t.Errorf("got Cache-Control %q on returned response, want %q", got, want)
+	}
+
+	clock.Advance(30 * time.Minute)
+
+	if got, want := do("http://example.com/hourly").Header.Get("Cache-Control"), "max-age=3600"; got != want {
+		t.Errorf("got Cache-Control %q on cached response, want %q", got, want)
+	}
+
+	if got, want := requests, 1; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+
+	_ = do("http://example.com/forced")
+
+	stored := do("http://example.com/forced")
+
+	if got, want := requests, 2; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+
+	if got := stored.Header.Get("Set-Cookie"); got != "" {
+		t.Errorf("got Set-Cookie %q on cached response, want none", got)
+	}
+
+	_ = do("http://example.com/other")
+	_ = do("http://example.com/other")
+
+	if got, want := requests, 4; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+}
+
+func TestClient_Do_OverrideRules_ForceStoreNotModified(t *testing.T) {
+	var requests int
+
+	client := &httpcache.Client{
+		Config: httpcache.Config{
+			OverrideRules: []httpcache.OverrideRule{
+				{MinTTL: OptValue(time.Minute), ForceStore: true},
+			},
+		},
+		Store: httpcache.NewMemoryStore(),
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				requests++
+
+				resp := newResp(
+					withRespHeader("Cache-Control", "max-age=60"),
+					withRespHeader("Etag", `"1"`),
+					withRespBody(strings.NewReader("body")))
+				if req.Header.Get("If-None-Match") == `"1"` {
+					resp = newResp(
+						withRespStatus(http.StatusNotModified),
+						withRespHeader("Cache-Control", "max-age=60"),
+						withRespHeader("Etag", `"1"`))
+				}
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	do := func(opts ...reqOpt) (int, string) {
+		t.Helper()
+
+		resp, err := client.Do(newReq(opts...))
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	if status, _ := do(withReqHeader("If-None-Match", `"1"`)); status != http.StatusNotModified {
+		t.Errorf("got status %d for conditional request, want %d", status, http.StatusNotModified)
+	}
+
+	if status, body := do(); status != http.StatusOK || body != "body" {
+		t.Errorf("got status %d and body %q, want %d and %q", status, body, http.StatusOK, "body")
+	}
+
+	if got, want := requests, 2; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+}
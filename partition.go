@@ -0,0 +1,124 @@
+package httpcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// PartitionHeader is the name of the request header used to pass the partition of a request to a [Store].
+//
+// If [Client.Partition] is set, [Client] sets this header on all requests passed to the [Store] to the value returned
+// by the function. Stores must treat the header as part of the primary cache key, so that responses stored for one
+// partition are never returned for another partition.
+//
+// Any value sent by the caller is replaced or removed and the header is never sent to the origin.
+const PartitionHeader = "Httpcache-Partition"
+
+// PartitionPurger is an optional interface that can be implemented by a [Store] to support removing all responses of
+// a partition at once, for example when a user logs out.
+type PartitionPurger interface {
+	// PurgePartition removes all stored responses of the given partition and returns the number of removed responses.
+	//
+	// Purging an empty or unknown partition is not an error.
+	PurgePartition(ctx context.Context, partition string) (int, error)
+}
+
+var errPurgePartitionUnsupported = fmt.Errorf("store does not implement PartitionPurger: %w", errors.ErrUnsupported)
+
+// PartitionByHeader returns a function for use with [Client.Partition] that partitions requests by the value of the
+// named request header, for example "Authorization".
+//
+// The value is hashed using SHA-256, so that the header value itself is never passed to the [Store]. Requests without
+// the header are not partitioned.
+func PartitionByHeader(name string) func(*http.Request) string {
+	name = http.CanonicalHeaderKey(name)
+
+	return func(req *http.Request) string {
+		values := req.Header[name]
+		if len(values) == 0 {
+			return ""
+		}
+
+		h := sha256.New()
+		for _, v := range values {
+			_, _ = h.Write([]byte(v))
+			_, _ = h.Write([]byte{0})
+		}
+		return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+	}
+}
+
+// PartitionByCookie returns a function for use with [Client.Partition] that partitions requests by the value of the
+// named cookie, for example a session cookie.
+//
+// The value is hashed using SHA-256, so that the cookie value itself is never passed to the [Store]. Requests without
+// the cookie are not partitioned.
+func PartitionByCookie(name string) func(*http.Request) string {
+	return func(req *http.Request) string {
+		cookie, err := req.Cookie(name)
+		if err != nil {
+			return ""
+		}
+
+		sum := sha256.Sum256([]byte(cookie.Value))
+		return base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+}
+
+// partition returns the request that is passed to the [Store] for the given request, with the [PartitionHeader] set
+// as returned by [Client.Partition] or removed if the request is not partitioned.
+//
+// If the header does not need to be changed, the request is returned as is.
+func (c *Client) partition(req *http.Request) *http.Request {
+	var partition string
+	if c.Partition != nil {
+		partition = c.Partition(req)
+	}
+
+	if values := req.Header[PartitionHeader]; (partition == "" && len(values) == 0) ||
+		(len(values) == 1 && values[0] == partition) {
+		return req
+	}
+
+	req = req.Clone(req.Context())
+
+	if partition == "" {
+		req.Header.Del(PartitionHeader)
+	} else {
+		req.Header.Set(PartitionHeader, partition)
+	}
+
+	return req
+}
+
+// PurgePartition removes all stored responses of the partition of the given request, as returned by
+// [Client.Partition], and returns the number of removed responses.
+//
+// This can be used to remove all responses of a user when the user logs out. The request must contain the same
+// information used for partitioning, for example the Authorization header.
+//
+// If the request is not partitioned, nothing is removed. If the [Store] does not implement [PartitionPurger], an error
+// matching [errors.ErrUnsupported] is returned.
+func (c *Client) PurgePartition(ctx context.Context, req *http.Request) (int, error) {
+	purger, ok := c.Store.(PartitionPurger)
+	if !ok {
+		return 0, errPurgePartitionUnsupported
+	}
+
+	partition := c.partition(req).Header.Get(PartitionHeader)
+	if partition == "" {
+		return 0, nil
+	}
+
+	n, err := purger.PurgePartition(ctx, partition)
+
+	if n > 0 || err != nil {
+		c.observe(ctx, nopSpan{}, Event{Type: EventInvalidated, Request: req, Reason: "purge-partition", Err: err})
+	}
+
+	return n, err
+}
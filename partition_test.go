@@ -0,0 +1,314 @@
+package httpcache_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nussjustin/httpcache"
+)
+
+func TestPartitionByHeader(t *testing.T) {
+	partition := httpcache.PartitionByHeader("authorization")
+
+	a := partition(newReq(withReqHeader("Authorization", "Bearer a")))
+	b := partition(newReq(withReqHeader("Authorization", "Bearer b")))
+
+	if a == "" || b == "" || a == b {
+		t.Errorf("got partitions %q and %q, want different non-empty partitions", a, b)
+	}
+
+	if strings.Contains(a, "Bearer") {
+		t.Errorf("partition %q contains header value", a)
+	}
+
+	if got := partition(newReq(withReqHeader("Authorization", "Bearer a"))); got != a {
+		t.Errorf("got partition %q for same header, want %q", got, a)
+	}
+
+	if got := partition(newReq()); got != "" {
+		t.Errorf("got partition %q for request without header, want none", got)
+	}
+}
+
+func TestPartitionByCookie(t *testing.T) {
+	partition := httpcache.PartitionByCookie("session")
+
+	a := partition(newReq(withReqHeader("Cookie", "theme=dark; session=a")))
+	b := partition(newReq(withReqHeader("Cookie", "theme=dark; session=b")))
+
+	if a == "" || b == "" || a == b {
+		t.Errorf("got partitions %q and %q, want different non-empty partitions", a, b)
+	}
+
+	if got := partition(newReq(withReqHeader("Cookie", "session=a; theme=light"))); got != a {
+		t.Errorf("got partition %q for same cookie, want %q", got, a)
+	}
+
+	if got := partition(newReq(withReqHeader("Cookie", "theme=dark"))); got != "" {
+		t.Errorf("got partition %q for request without cookie, want none", got)
+	}
+}
+
+func TestMemoryStore_MaxPartitionEntries(t *testing.T) {
+	stores := map[string]httpcache.Store{
+		"MemoryStore":        &httpcache.MemoryStore{MaxPartitionEntries: 2},
+		"ShardedMemoryStore": &httpcache.ShardedMemoryStore{MaxPartitionEntries: 2},
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			set := func(path, partition string) {
+				t.Helper()
+
+				req := newReq(withReqUrl("http://example.com"+path), withReqHeader(httpcache.PartitionHeader, partition))
+
+				if err := store.Set(t.Context(), req, time.Now(), newResp(), time.Now()); err != nil {
+					t.Fatalf("Set() error = %v", err)
+				}
+			}
+
+			has := func(path, partition string) bool {
+				t.Helper()
+
+				req := newReq(withReqUrl("http://example.com"+path), withReqHeader(httpcache.PartitionHeader, partition))
+
+				resp, err := store.Get(t.Context(), req)
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				return resp != nil
+			}
+
+			set("/1", "a")
+			set("/2", "a")
+			set("/1", "b")
+
+			// Replacing a response must not count against the quota.
+			set("/1", "a")
+			set("/3", "a")
+
+			for _, tt := range []struct {
+				path, partition string
+				want            bool
+			}{
+				{"/1", "a", true},
+				{"/2", "a", false},
+				{"/3", "a", true},
+				{"/1", "b", true},
+			} {
+				if got := has(tt.path, tt.partition); got != tt.want {
+					t.Errorf("Get(%s) for partition %q returned response = %v, want %v", tt.path, tt.partition, got, tt.want)
+				}
+			}
+
+			stats, err := store.(httpcache.StoreStatsReporter).Stats(t.Context())
+			if err != nil {
+				t.Fatalf("Stats() error = %v", err)
+			}
+
+			if got, want := stats.Entries, 3; got != want {
+				t.Errorf("got %d entries, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestClient_Do_Partition(t *testing.T) {
+	var requests int
+
+	client := &httpcache.Client{
+		Config:    httpcache.Config{Private: true},
+		Store:     httpcache.NewMemoryStore(),
+		Partition: httpcache.PartitionByHeader("Authorization"),
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				requests++
+
+				if got := req.Header.Get(httpcache.PartitionHeader); got != "" {
+					t.Errorf("got partition %q in request sent to origin", got)
+				}
+
+				resp := newResp(
+					withRespHeader("Cache-Control", "private, max-age=60"),
+					withRespBody(strings.NewReader(req.Header.Get("Authorization"))))
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	do := func(auth string, opts ...reqOpt) string {
+		t.Helper()
+
+		if auth != "" {
+			opts = append(opts, withReqHeader("Authorization", auth))
+		}
+
+		resp, err := client.Do(newReq(opts...))
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+		return string(body)
+	}
+
+	for _, auth := range []string{"a", "b", "", "a", "b", ""} {
+		if got := do(auth); got != auth {
+			t.Errorf("got body %q for user %q", got, auth)
+		}
+	}
+
+	if got, want := requests, 3; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+
+	// A partition sent by the caller must not be used.
+	partition := httpcache.PartitionByHeader("Authorization")(newReq(withReqHeader("Authorization", "a")))
+
+	if got := do("", withReqHeader(httpcache.PartitionHeader, partition)); got != "" {
+		t.Errorf("got body %q for spoofed partition, want none", got)
+	}
+
+	n, err := client.PurgePartition(t.Context(), newReq(withReqHeader("Authorization", "a")))
+	if err != nil || n != 1 {
+		t.Errorf("PurgePartition() = %d, %v, want 1, nil", n, err)
+	}
+
+	_ = do("a")
+	_ = do("b")
+
+	if got, want := requests, 4; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+
+	n, err = client.PurgePartition(t.Context(), newReq())
+	if err != nil || n != 0 {
+		t.Errorf("PurgePartition() for unpartitioned request = %d, %v, want 0, nil", n, err)
+	}
+}
+
+func TestClient_Do_Partition_Invalidate(t *testing.T) {
+	partition := httpcache.PartitionByHeader("Authorization")
+
+	tests := []struct {
+		name   string
+		store  func(store *httpcache.MemoryStore) httpcache.Store
+		remain []string
+	}{
+		{
+			name:   "purger",
+			store:  func(store *httpcache.MemoryStore) httpcache.Store { return store },
+			remain: nil,
+		},
+		{
+			name: "lister",
+			store: func(store *httpcache.MemoryStore) httpcache.Store {
+				return struct {
+					httpcache.Store
+					httpcache.StoreDeleter
+					httpcache.StoreLister
+				}{store, store, store}
+			},
+			remain: nil,
+		},
+		{
+			name: "no lister",
+			store: func(store *httpcache.MemoryStore) httpcache.Store {
+				return struct {
+					httpcache.Store
+					httpcache.StoreDeleter
+				}{store, store}
+			},
+			remain: []string{partition(newReq(withReqHeader("Authorization", "b")))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := httpcache.NewMemoryStore()
+
+			client := &httpcache.Client{
+				Store:     tt.store(store),
+				Partition: partition,
+				HTTPClient: &http.Client{
+					Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+						resp := newResp(withRespHeader("Cache-Control", "public, max-age=60"))
+						resp.Request = req
+						return resp, nil
+					}),
+				},
+			}
+
+			for _, auth := range []string{"a", "b", ""} {
+				req := newReq()
+				if auth != "" {
+					req.Header.Set("Authorization", auth)
+				}
+
+				if _, err := client.Do(req); err != nil {
+					t.Fatalf("Do() error = %v", err)
+				}
+			}
+
+			if _, err := client.Do(newReq(withReqMethod("POST"), withReqHeader("Authorization", "a"))); err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+
+			var partitions []string
+
+			for e, err := range store.Entries(t.Context()) {
+				if err != nil {
+					t.Fatalf("Entries() error = %v", err)
+				}
+				partitions = append(partitions, e.Partition)
+			}
+
+			if !slices.Equal(partitions, tt.remain) {
+				t.Errorf("got partitions %q after invalidation, want %q", partitions, tt.remain)
+			}
+		})
+	}
+}
+
+func TestClient_Do_Partition_Bypass(t *testing.T) {
+	client := &httpcache.Client{
+		Store: httpcache.NewMemoryStore(),
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if got := req.Header.Get(httpcache.PartitionHeader); got != "" {
+					t.Errorf("got partition %q in request sent to origin", got)
+				}
+
+				resp := newResp()
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	req := newReq(withReqMethod("POST"), withReqHeader(httpcache.PartitionHeader, "spoofed"))
+
+	if _, err := client.Do(req); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if got := req.Header.Get(httpcache.PartitionHeader); got != "spoofed" {
+		t.Errorf("request was modified, got partition %q", got)
+	}
+}
+
+func TestClient_PurgePartition_Unsupported(t *testing.T) {
+	client := &httpcache.Client{Store: &trackingStore{Store: httpcache.NewMemoryStore()}}
+
+	if _, err := client.PurgePartition(t.Context(), newReq()); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("PurgePartition() error = %v, want %v", err, errors.ErrUnsupported)
+	}
+}
@@ -0,0 +1,103 @@
+package httpcache
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// RequestPolicy overrides the behavior of a [Client] for a single request.
+//
+// A RequestPolicy is attached to the context of a request using [WithRequestPolicy]. Most options are combined with
+// the Cache-Control header of the request, so that the stricter of both is used. TTL is an exception: it overrides the
+// freshness lifetime of the stored response and can both shorten and extend it.
+//
+// The zero value does not change the behavior of the Client.
+type RequestPolicy struct {
+	// Bypass causes the request to be sent without looking up or storing responses, as if the request method was not
+	// supported.
+	Bypass bool
+
+	// Refresh causes a stored response to be validated before being used, even if it is fresh or immutable, the same
+	// as the no-cache request directive.
+	//
+	// If the stored response can not be validated, the request is sent as if no response was stored. The new response
+	// is stored as usual.
+	Refresh bool
+
+	// OnlyIfCached causes a 504 (Gateway Timeout) response to be returned when no usable response is stored, instead
+	// of sending the request, the same as the only-if-cached request directive.
+	//
+	// Requests that bypass the cache, for example because of Bypass or because their method is not supported, are
+	// still sent.
+	OnlyIfCached bool
+
+	// MaxStale, if set, limits how long after becoming stale a response can be used without validation.
+	//
+	// This only limits the value of the max-stale request directive, if any. A value of 0 disables the use of stale
+	// responses.
+	MaxStale Opt[time.Duration]
+
+	// TTL, if set, is used in place of the freshness lifetime of the stored response when checking if it is fresh,
+	// even if it is longer than the lifetime given by the origin.
+	//
+	// This does not change if or how responses are stored.
+	TTL Opt[time.Duration]
+}
+
+// String returns a textual representation of the policy for use in logs.
+//
+// The result is a comma separated list of the enabled options, similar to a Cache-Control header, for example
+// "refresh, ttl=60". Durations are given in seconds, rounded up.
+func (p RequestPolicy) String() string {
+	var parts []string
+
+	if p.Bypass {
+		parts = append(parts, "bypass")
+	}
+
+	if p.Refresh {
+		parts = append(parts, "refresh")
+	}
+
+	if p.OnlyIfCached {
+		parts = append(parts, "only-if-cached")
+	}
+
+	if p.MaxStale.Valid {
+		parts = append(parts, "max-stale="+formatDeltaSeconds(p.MaxStale.Value))
+	}
+
+	if p.TTL.Valid {
+		parts = append(parts, "ttl="+formatDeltaSeconds(p.TTL.Value))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// apply changes the given request directives according to the policy.
+func (p RequestPolicy) apply(reqDirectives *RequestDirectives) {
+	if p.OnlyIfCached {
+		reqDirectives.OnlyIfCached = true
+	}
+
+	if p.MaxStale.Valid && reqDirectives.MaxStale.Valid {
+		reqDirectives.MaxStale.Value = min(reqDirectives.MaxStale.Value, p.MaxStale.Value)
+	}
+}
+
+type requestPolicyKey struct{}
+
+// WithRequestPolicy returns a copy of ctx with the given policy attached.
+//
+// Requests using the returned context are handled by [Client.Do] according to the policy. The policy is also passed
+// to the [Observer] using [Event.Policy].
+func WithRequestPolicy(ctx context.Context, policy RequestPolicy) context.Context {
+	return context.WithValue(ctx, requestPolicyKey{}, policy)
+}
+
+// RequestPolicyFromContext returns the policy attached to ctx using [WithRequestPolicy], if any.
+func RequestPolicyFromContext(ctx context.Context) (RequestPolicy, bool) {
+	policy, ok := ctx.Value(requestPolicyKey{}).(RequestPolicy)
+	return policy, ok
+}
@@ -0,0 +1,195 @@
+package httpcache_test
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nussjustin/httpcache"
+)
+
+func TestClient_Do_RequestPolicy(t *testing.T) {
+	clock := httpcache.NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	observer := &recordingObserver{}
+
+	var requests []*http.Request
+
+	client := &httpcache.Client{
+		Config:   httpcache.Config{Clock: clock},
+		Store:    &httpcache.MemoryStore{Clock: clock},
+		Observer: observer,
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				requests = append(requests, req)
+
+				resp := newResp(
+					withRespHeader("Cache-Control", "max-age=60, immutable"),
+					withRespHeader("Etag", `"tag"`))
+				if req.Header.Get("If-None-Match") != "" {
+					resp = newResp(withRespStatus(http.StatusNotModified))
+				}
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	tests := []struct {
+		name        string
+		req         *http.Request
+		policy      httpcache.RequestPolicy
+		advance     time.Duration
+		wantStatus  int
+		wantRequest bool
+		wantEvent   httpcache.EventType
+	}{
+		{
+			name:        "bypass",
+			policy:      httpcache.RequestPolicy{Bypass: true},
+			wantStatus:  http.StatusOK,
+			wantRequest: true,
+			wantEvent:   httpcache.EventBypass,
+		},
+		{
+			name:       "only-if-cached without stored response",
+			policy:     httpcache.RequestPolicy{OnlyIfCached: true},
+			wantStatus: http.StatusGatewayTimeout,
+			wantEvent:  httpcache.EventMiss,
+		},
+		{
+			name:        "miss",
+			wantStatus:  http.StatusOK,
+			wantRequest: true,
+			wantEvent:   httpcache.EventStored,
+		},
+		{
+			name:       "only-if-cached with stored response",
+			policy:     httpcache.RequestPolicy{OnlyIfCached: true},
+			wantStatus: http.StatusOK,
+			wantEvent:  httpcache.EventHit,
+		},
+		{
+			name:        "refresh of immutable response",
+			policy:      httpcache.RequestPolicy{Refresh: true},
+			wantStatus:  http.StatusOK,
+			wantRequest: true,
+			wantEvent:   httpcache.EventStoreSet,
+		},
+		{
+			name:        "ttl shorter than freshness lifetime",
+			policy:      httpcache.RequestPolicy{TTL: OptValue(10 * time.Second)},
+			advance:     30 * time.Second,
+			wantStatus:  http.StatusOK,
+			wantRequest: true,
+			wantEvent:   httpcache.EventStoreSet,
+		},
+		{
+			name:       "ttl longer than freshness lifetime",
+			policy:     httpcache.RequestPolicy{TTL: OptValue(5 * time.Minute)},
+			advance:    2 * time.Minute,
+			wantStatus: http.StatusOK,
+			wantEvent:  httpcache.EventHit,
+		},
+		{
+			name:       "max-stale from request",
+			req:        newReq(withReqHeader("Cache-Control", "max-stale=120")),
+			wantStatus: http.StatusOK,
+			wantEvent:  httpcache.EventStaleServed,
+		},
+		{
+			name:        "max-stale capped by policy",
+			req:         newReq(withReqHeader("Cache-Control", "max-stale=120")),
+			policy:      httpcache.RequestPolicy{MaxStale: OptValue(30 * time.Second)},
+			wantStatus:  http.StatusOK,
+			wantRequest: true,
+			wantEvent:   httpcache.EventStoreSet,
+		},
+	}
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+
+		requests = nil
+
+		req := tt.req
+		if req == nil {
+			req = newReq()
+		}
+
+		req = req.WithContext(httpcache.WithRequestPolicy(req.Context(), tt.policy))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: Do() error = %v", tt.name, err)
+		}
+
+		if got, want := resp.StatusCode, tt.wantStatus; got != want {
+			t.Errorf("%s: got status %d, want %d", tt.name, got, want)
+		}
+
+		if got, want := len(requests) != 0, tt.wantRequest; got != want {
+			t.Errorf("%s: sent request = %v, want %v", tt.name, got, want)
+		}
+
+		observer.mu.Lock()
+		events := slices.Clone(observer.events)
+		observer.mu.Unlock()
+
+		if got, want := events[len(events)-1].Type, tt.wantEvent; got != want {
+			t.Errorf("%s: got last event %s, want %s", tt.name, got, want)
+		}
+
+		for _, e := range events {
+			if e.Policy == nil || *e.Policy != tt.policy {
+				t.Errorf("%s: got policy %v for event %s, want %v", tt.name, e.Policy, e.Type, tt.policy)
+			}
+		}
+
+		_ = observer.types()
+	}
+}
+
+func TestRequestPolicy_String(t *testing.T) {
+	tests := []struct {
+		policy httpcache.RequestPolicy
+		want   string
+	}{
+		{policy: httpcache.RequestPolicy{}, want: ""},
+		{policy: httpcache.RequestPolicy{Bypass: true}, want: "bypass"},
+		{
+			policy: httpcache.RequestPolicy{
+				Refresh:      true,
+				OnlyIfCached: true,
+				MaxStale:     OptValue(30 * time.Second),
+				TTL:          OptValue(time.Minute),
+			},
+			want: "refresh, only-if-cached, max-stale=30, ttl=60",
+		},
+		{
+			policy: httpcache.RequestPolicy{
+				MaxStale: OptValue(500 * time.Millisecond),
+				TTL:      OptValue(1500 * time.Millisecond),
+			},
+			want: "max-stale=1, ttl=2",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestRequestPolicyFromContext(t *testing.T) {
+	if _, ok := httpcache.RequestPolicyFromContext(t.Context()); ok {
+		t.Error("RequestPolicyFromContext() on empty context returned ok")
+	}
+
+	want := httpcache.RequestPolicy{Refresh: true}
+
+	got, ok := httpcache.RequestPolicyFromContext(httpcache.WithRequestPolicy(t.Context(), want))
+	if !ok || got != want {
+		t.Errorf("RequestPolicyFromContext() = %v, %v, want %v, true", got, ok, want)
+	}
+}
@@ -0,0 +1,323 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RefreshAhead configures the refreshing of frequently used responses before they become stale.
+//
+// The zero value disables refresh-ahead.
+type RefreshAhead struct {
+	// Fraction is the fraction at the end of the freshness lifetime of a stored response during which the response is
+	// refreshed, for example 0.1 to refresh responses during the last 10% of their lifetime.
+	//
+	// Refresh-ahead is disabled if Fraction is zero or negative.
+	Fraction float64
+
+	// MinHits is the number of times a stored response must have been returned from the cache before it is refreshed,
+	// counting all uses since the response was stored.
+	//
+	// If zero or negative, a response is refreshed on its first use during the refresh period.
+	MinHits int
+
+	// Concurrency is the maximum number of responses revalidated in the background at the same time, including stale
+	// responses revalidated because of [Client.RevalidateStaleInBackground]. Responses that qualify for a revalidation
+	// while the limit is reached are not revalidated.
+	//
+	// If zero or negative, only one response is revalidated at a time.
+	Concurrency int
+}
+
+// background holds the state of the background operations started by a [Client].
+type background struct {
+	once   sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// sem limits the number of concurrent background revalidations. See RefreshAhead.Concurrency.
+	sem chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+
+	// inflight contains the keys of the responses that are currently revalidated in the background.
+	inflight map[string]struct{}
+
+	hits    map[string]*refreshAheadEntry
+	pruneAt int
+}
+
+// refreshAheadEntry tracks the uses of a single stored response for refresh-ahead.
+type refreshAheadEntry struct {
+	storedAt time.Time
+	expires  time.Time
+	hits     int
+}
+
+// background returns the lazily initialized background state of the client.
+func (c *Client) background() *background {
+	b := &c.bg
+
+	b.once.Do(func() {
+		b.ctx, b.cancel = context.WithCancel(context.Background())
+		b.sem = make(chan struct{}, max(c.RefreshAhead.Concurrency, 1))
+		b.inflight = make(map[string]struct{})
+		b.hits = make(map[string]*refreshAheadEntry)
+	})
+
+	return b
+}
+
+// goBackground calls f in a new goroutine with a context that keeps the values of ctx, but is only canceled by
+// [Client.Close].
+//
+// If the client was already closed, f is not called and false is returned.
+func (c *Client) goBackground(ctx context.Context, f func(ctx context.Context)) bool {
+	b := c.background()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(b.ctx, cancel)
+
+	b.wg.Go(func() {
+		defer cancel()
+		defer stop()
+
+		f(ctx)
+	})
+
+	return true
+}
+
+// backgroundKey returns the key used to track background revalidations of the stored response for the given request.
+func backgroundKey(req *http.Request, info ResponseInfo) string {
+	return req.Method + " " + req.URL.String() + " " + req.Header.Get(PartitionHeader) + " " + info.VaryKey
+}
+
+// tryAcquire marks the response with the given key as being revalidated and acquires a slot of the concurrency limit.
+//
+// It returns false if the response is already being revalidated, the limit is reached or the client was closed.
+//
+// b.mu must be held by the caller.
+func (b *background) tryAcquire(key string) bool {
+	if b.closed {
+		return false
+	}
+
+	if _, ok := b.inflight[key]; ok {
+		return false
+	}
+
+	select {
+	case b.sem <- struct{}{}:
+	default:
+		return false
+	}
+
+	b.inflight[key] = struct{}{}
+
+	return true
+}
+
+// release undoes a successful call to [background.tryAcquire].
+func (b *background) release(key string) {
+	b.mu.Lock()
+	delete(b.inflight, key)
+	b.mu.Unlock()
+
+	<-b.sem
+}
+
+// revalidateInBackground validates the given stored response in a new goroutine, updating the store with the result.
+//
+// Only one revalidation is run for each stored response at a time and the number of concurrent revalidations is limited
+// as configured by [RefreshAhead.Concurrency]. If the response is already being revalidated or the limit is reached,
+// the response is not revalidated.
+//
+// The response must have been passed to [Client.setStoredInfo] before.
+func (c *Client) revalidateInBackground(ctx context.Context, config *Config, req *http.Request, stored *http.Response) {
+	info, ok := knownInfo(stored)
+	if !ok {
+		return
+	}
+
+	key := backgroundKey(normalize(config, req), info)
+
+	b := c.background()
+
+	b.mu.Lock()
+	ok = b.tryAcquire(key)
+	b.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	c.goRevalidate(ctx, config, "httpcache.Client.Revalidate", req, stored, func() { b.release(key) })
+}
+
+// goRevalidate validates the given stored response in a new goroutine using [Client.goBackground] and calls done once
+// the validation finished or could not be started.
+func (c *Client) goRevalidate(
+	ctx context.Context,
+	config *Config,
+	name string,
+	req *http.Request,
+	stored *http.Response,
+	done func(),
+) {
+	storedCopy, err := cloneResponse(stored)
+	if err != nil {
+		done()
+		return
+	}
+
+	if !hasValidators(req, storedCopy) {
+		storedCopy = nil
+	}
+
+	// The request is cloned before starting the goroutine, so that it does not share the headers of the request with
+	// the caller, who may modify them once Do returned.
+	req = req.Clone(ctx)
+
+	started := c.goBackground(ctx, func(ctx context.Context) {
+		defer done()
+
+		ctx, span := c.startSpan(ctx, name)
+		defer span.End()
+
+		resp, err := c.fetch(ctx, span, config, req.WithContext(ctx), storedCopy)
+		if err != nil {
+			span.RecordError(err)
+			return
+		}
+
+		discardBody(resp)
+	})
+
+	if !started {
+		done()
+	}
+}
+
+// Close cancels all background revalidations and refreshes started by the client and waits for them to finish.
+//
+// The client can still be used after Close, but no longer starts new background operations.
+func (c *Client) Close() error {
+	b := c.background()
+
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	b.cancel()
+	b.wg.Wait()
+
+	return nil
+}
+
+// refreshAhead records the use of a fresh stored response and, if the response is near the end of its freshness
+// lifetime and was used often enough, refreshes it in the background as configured by [Client.RefreshAhead].
+//
+// The response must have been passed to [Client.setStoredInfo] before.
+func (c *Client) refreshAhead(ctx context.Context, config *Config, req *http.Request, stored *http.Response) {
+	opts := c.RefreshAhead
+
+	if opts.Fraction <= 0 {
+		return
+	}
+
+	info, ok := Info(stored)
+	if !ok || info.TTL <= 0 {
+		return
+	}
+
+	key := backgroundKey(normalize(config, req), info)
+
+	entry, ok := c.trackRefreshAhead(config, key, info, opts)
+	if !ok {
+		return
+	}
+
+	b := c.background()
+
+	c.goRevalidate(ctx, config, "httpcache.Client.RefreshAhead", req, stored, func() {
+		b.mu.Lock()
+		if b.hits[key] == entry {
+			delete(b.hits, key)
+		}
+		b.mu.Unlock()
+
+		b.release(key)
+	})
+}
+
+// trackRefreshAhead counts a use of the stored response with the given key and returns its entry if the response
+// should be refreshed.
+//
+// If true is returned, the response was marked as being revalidated using [background.tryAcquire].
+func (c *Client) trackRefreshAhead(
+	config *Config,
+	key string,
+	info ResponseInfo,
+	opts RefreshAhead,
+) (*refreshAheadEntry, bool) {
+	b := c.background()
+
+	now := config.now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, false
+	}
+
+	entry := b.hits[key]
+	if entry == nil || !entry.storedAt.Equal(info.StoredAt) {
+		if entry == nil && len(b.hits) >= b.pruneAt {
+			b.prune(now)
+		}
+
+		entry = &refreshAheadEntry{storedAt: info.StoredAt}
+		b.hits[key] = entry
+	}
+
+	entry.hits++
+	entry.expires = now.Add(info.TTL)
+
+	if entry.hits < opts.MinHits {
+		return nil, false
+	}
+
+	if float64(info.TTL) > opts.Fraction*float64(info.FreshnessLifetime) {
+		return nil, false
+	}
+
+	if !b.tryAcquire(key) {
+		return nil, false
+	}
+
+	return entry, true
+}
+
+// prune removes the entries of responses that became stale without being refreshed.
+func (b *background) prune(now time.Time) {
+	for key, entry := range b.hits {
+		if _, refreshing := b.inflight[key]; !refreshing && !entry.expires.After(now) {
+			delete(b.hits, key)
+		}
+	}
+
+	b.pruneAt = max(2*len(b.hits), 64)
+}
@@ -0,0 +1,280 @@
+package httpcache_test
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/nussjustin/httpcache"
+)
+
+func TestClient_Do_RefreshAhead(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		clock := httpcache.NewFakeClock(start)
+
+		var (
+			mu          sync.Mutex
+			requests    []string
+			conditional int
+		)
+
+		client := &httpcache.Client{
+			Config: httpcache.Config{Clock: clock},
+			Store:  &httpcache.MemoryStore{Clock: clock},
+			HTTPClient: &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					mu.Lock()
+					requests = append(requests, req.URL.Path)
+					status := http.StatusOK
+					if req.Header.Get("If-None-Match") != "" {
+						conditional++
+						status = http.StatusNotModified
+					}
+					mu.Unlock()
+
+					resp := newResp(
+						withRespStatus(status),
+						withRespHeader("Cache-Control", "max-age=100"),
+						withRespHeader("Date", clock.Now().Format(http.TimeFormat)),
+						withRespHeader("Etag", `"1"`))
+					resp.Request = req
+					return resp, nil
+				}),
+			},
+			RefreshAhead: httpcache.RefreshAhead{Fraction: 0.2, MinHits: 2},
+		}
+		defer func() { _ = client.Close() }()
+
+		do := func(path string) httpcache.ResponseInfo {
+			t.Helper()
+
+			resp, err := client.Do(newReq(withReqUrl("http://example.com" + path)))
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+
+			info, _ := httpcache.Info(resp)
+
+			synctest.Wait()
+
+			return info
+		}
+
+		expectRequests := func(want int) {
+			t.Helper()
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if got := len(requests); got != want {
+				t.Errorf("got %d requests, want %d", got, want)
+			}
+		}
+
+		_ = do("/a")
+		_ = do("/b")
+
+		clock.Advance(50 * time.Second)
+
+		_ = do("/a")
+
+		expectRequests(2)
+
+		clock.Advance(35 * time.Second)
+
+		// Used twice, once during the refresh period.
+		_ = do("/a")
+
+		// Used only once.
+		_ = do("/b")
+
+		expectRequests(3)
+
+		if conditional != 1 {
+			t.Errorf("got %d conditional requests, want 1", conditional)
+		}
+
+		if got, want := requests[2], "/a"; got != want {
+			t.Errorf("got refresh for %s, want %s", got, want)
+		}
+
+		info := do("/a")
+
+		if got, want := info.StoredAt, start.Add(85*time.Second); !got.Equal(want) {
+			t.Errorf("got response stored at %s, want %s", got, want)
+		}
+
+		if got, want := info.TTL, 100*time.Second; got != want {
+			t.Errorf("got TTL %s, want %s", got, want)
+		}
+
+		expectRequests(3)
+	})
+}
+
+func TestClient_Close(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		clock := httpcache.NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+		var (
+			mu       sync.Mutex
+			inFlight int
+			canceled int
+		)
+
+		client := &httpcache.Client{
+			Config: httpcache.Config{Clock: clock},
+			Store:  &httpcache.MemoryStore{Clock: clock},
+			HTTPClient: &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					if req.Header.Get("If-None-Match") != "" {
+						mu.Lock()
+						inFlight++
+						mu.Unlock()
+
+						<-req.Context().Done()
+
+						mu.Lock()
+						canceled++
+						mu.Unlock()
+
+						return nil, req.Context().Err()
+					}
+
+					resp := newResp(
+						withRespHeader("Cache-Control", "max-age=100"),
+						withRespHeader("Date", clock.Now().Format(http.TimeFormat)),
+						withRespHeader("Etag", `"1"`))
+					resp.Request = req
+					return resp, nil
+				}),
+			},
+			RefreshAhead: httpcache.RefreshAhead{Fraction: 0.5},
+		}
+
+		do := func(path string) {
+			t.Helper()
+
+			if _, err := client.Do(newReq(withReqUrl("http://example.com" + path))); err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+		}
+
+		do("/a")
+		do("/b")
+
+		clock.Advance(60 * time.Second)
+
+		do("/a")
+		do("/b")
+
+		synctest.Wait()
+
+		mu.Lock()
+		if inFlight != 1 {
+			t.Errorf("got %d refreshes in flight, want 1", inFlight)
+		}
+		mu.Unlock()
+
+		if err := client.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+
+		if canceled != 1 {
+			t.Errorf("got %d canceled refreshes, want 1", canceled)
+		}
+
+		// No new refreshes are started after Close.
+		do("/b")
+
+		synctest.Wait()
+
+		if inFlight != 1 {
+			t.Errorf("got %d refreshes after Close, want 1", inFlight)
+		}
+	})
+}
+
+func TestClient_Do_RevalidateStaleInBackground_Limit(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var (
+			mu          sync.Mutex
+			conditional []string
+		)
+
+		release := make(chan struct{})
+
+		client := &httpcache.Client{
+			Store:                       httpcache.NewMemoryStore(),
+			RevalidateStaleInBackground: true,
+			HTTPClient: &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					status := http.StatusOK
+
+					if req.Header.Get("If-None-Match") != "" {
+						mu.Lock()
+						conditional = append(conditional, req.URL.Path)
+						mu.Unlock()
+
+						<-release
+
+						status = http.StatusNotModified
+					}
+
+					resp := newResp(
+						withRespStatus(status),
+						withRespHeader("Cache-Control", "max-age=30"),
+						withRespHeader("Etag", `"1"`))
+					resp.Request = req
+					return resp, nil
+				}),
+			},
+		}
+		defer func() { _ = client.Close() }()
+
+		do := func(path string, opts ...reqOpt) {
+			t.Helper()
+
+			req := newReq(append([]reqOpt{withReqUrl("http://example.com" + path)}, opts...)...)
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			_ = resp.Body.Close()
+
+			// Background revalidations must not share the request with the caller.
+			req.Header.Set("If-None-Match", `"2"`)
+		}
+
+		do("/a")
+		do("/b")
+
+		time.Sleep(time.Minute)
+
+		for range 3 {
+			do("/a", withReqHeader("Cache-Control", "max-stale"))
+		}
+
+		// The limit of one concurrent revalidation is reached.
+		do("/b", withReqHeader("Cache-Control", "max-stale"))
+
+		synctest.Wait()
+
+		close(release)
+
+		synctest.Wait()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if want := []string{"/a"}; !slices.Equal(conditional, want) {
+			t.Errorf("got conditional requests %q, want %q", conditional, want)
+		}
+	})
+}
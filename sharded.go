@@ -0,0 +1,461 @@
+package httpcache
+
+import (
+	"cmp"
+	"context"
+	"encoding/hex"
+	"hash/maphash"
+	"iter"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryShard holds the entries for a subset of the keys of a [MemoryStore] or [ShardedMemoryStore].
+//
+// The variant slices stored in the map are never modified, only replaced, so that they can be used after releasing
+// the lock.
+//
+// All entries of a partition are stored in the same shard.
+//
+// The zero value is ready to use.
+type memoryShard struct {
+	mu      sync.RWMutex
+	entries map[string][]*memoryStoreEntry
+
+	// partitions contains the entries of each partition in the order in which they were stored.
+	partitions map[string][]*memoryStoreEntry
+
+	// urls contains the partitioned entries by the key of their method and URL without the partition.
+	urls map[string][]*memoryStoreEntry
+}
+
+func (s *memoryShard) get(key string, req *http.Request, now time.Time) *http.Response {
+	s.mu.RLock()
+	variants := s.entries[key]
+	s.mu.RUnlock()
+
+	// Most responses for the same key use the same Vary header, so the secondary key for the request is only computed
+	// again if the Vary header of the entry differs from the last one.
+	var (
+		vary    Vary
+		varyKey []byte
+	)
+
+	for i, entry := range variants {
+		if i == 0 || !slices.Equal(vary, entry.vary) {
+			vary, varyKey = entry.vary, entry.vary.Key(varyKey[:0], req.Header)
+		}
+
+		if entry.varyKey != string(varyKey) {
+			continue
+		}
+
+		return entry.restore(now)
+	}
+
+	return nil
+}
+
+// set stores the given entry, replacing any existing entry with the same key and variant.
+//
+// If maxPartitionEntries is greater than zero and the partition of the entry contains more entries after storing it,
+// the oldest entries of the partition are removed.
+func (s *memoryShard) set(entry *memoryStoreEntry, maxPartitionEntries int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	variants := s.entries[entry.key]
+
+	i := slices.IndexFunc(variants, func(e *memoryStoreEntry) bool { return e.varyKey == entry.varyKey })
+
+	if i == -1 {
+		variants = append(slices.Clip(variants), entry)
+	} else {
+		s.unlinkPartition(variants[i])
+
+		variants = slices.Clone(variants)
+		variants[i] = entry
+	}
+
+	if s.entries == nil {
+		s.entries = make(map[string][]*memoryStoreEntry)
+	}
+
+	s.entries[entry.key] = variants
+
+	if entry.partition == "" {
+		return
+	}
+
+	if s.partitions == nil {
+		s.partitions = make(map[string][]*memoryStoreEntry)
+		s.urls = make(map[string][]*memoryStoreEntry)
+	}
+
+	s.urls[entry.urlKey] = append(s.urls[entry.urlKey], entry)
+
+	list := append(s.partitions[entry.partition], entry)
+
+	for maxPartitionEntries > 0 && len(list) > maxPartitionEntries {
+		s.removeVariant(list[0])
+		removeEntry(s.urls, list[0].urlKey, list[0])
+		list = slices.Delete(list, 0, 1)
+	}
+
+	s.partitions[entry.partition] = list
+}
+
+// removeVariant removes the given entry from the entries of its key, but not from its partition.
+func (s *memoryShard) removeVariant(entry *memoryStoreEntry) {
+	variants := slices.DeleteFunc(slices.Clone(s.entries[entry.key]), func(e *memoryStoreEntry) bool { return e == entry })
+
+	if len(variants) == 0 {
+		delete(s.entries, entry.key)
+	} else {
+		s.entries[entry.key] = variants
+	}
+}
+
+// unlinkPartition removes the given entry from its partition, if any.
+func (s *memoryShard) unlinkPartition(entry *memoryStoreEntry) {
+	if entry.partition == "" {
+		return
+	}
+
+	removeEntry(s.partitions, entry.partition, entry)
+	removeEntry(s.urls, entry.urlKey, entry)
+}
+
+// removeEntry removes the given entry from the list with the given key in m.
+func removeEntry(m map[string][]*memoryStoreEntry, key string, entry *memoryStoreEntry) {
+	list := slices.DeleteFunc(m[key], func(e *memoryStoreEntry) bool { return e == entry })
+
+	if len(list) == 0 {
+		delete(m, key)
+	} else {
+		m[key] = list
+	}
+}
+
+func (s *memoryShard) purgeTags(mode PurgeMode, tags []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+
+	for key, variants := range s.entries {
+		kept := make([]*memoryStoreEntry, 0, len(variants))
+
+		for _, entry := range variants {
+			if !slices.ContainsFunc(tags, func(tag string) bool { return slices.Contains(entry.tags, tag) }) {
+				kept = append(kept, entry)
+				continue
+			}
+
+			n++
+
+			if mode == PurgeSoft {
+				entry.purged.Store(true)
+				kept = append(kept, entry)
+			} else {
+				s.unlinkPartition(entry)
+			}
+		}
+
+		switch {
+		case len(kept) == 0:
+			delete(s.entries, key)
+		case len(kept) != len(variants):
+			s.entries[key] = kept
+		}
+	}
+
+	return n
+}
+
+func (s *memoryShard) purgePartition(partition string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.partitions[partition]
+
+	for _, entry := range list {
+		s.removeVariant(entry)
+		removeEntry(s.urls, entry.urlKey, entry)
+	}
+
+	delete(s.partitions, partition)
+
+	return len(list)
+}
+
+// purgeURL removes the unpartitioned entries with the given key as well as the partitioned entries with the given
+// URL key.
+func (s *memoryShard) purgeURL(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.entries[key])
+
+	delete(s.entries, key)
+
+	list := s.urls[key]
+
+	delete(s.urls, key)
+
+	for _, entry := range list {
+		s.removeVariant(entry)
+		s.unlinkPartition(entry)
+	}
+
+	return n + len(list)
+}
+
+func (s *memoryShard) appendEntries(dst []StoreEntry, now time.Time) []StoreEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for key, variants := range s.entries {
+		for _, entry := range variants {
+			dst = append(dst, StoreEntry{
+				Key:        key,
+				Partition:  entry.partition,
+				Method:     entry.req.Method,
+				URL:        entry.req.URL.String(),
+				Variant:    hex.EncodeToString([]byte(entry.varyKey)),
+				StatusCode: entry.resp.StatusCode,
+				Header:     cloneHeader(entry.resp.Header),
+				Size:       int64(len(entry.respBody)),
+				Age:        entry.age(now),
+			})
+		}
+	}
+
+	return dst
+}
+
+func (s *memoryShard) delete(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	variants := s.entries[key]
+
+	for _, entry := range variants {
+		s.unlinkPartition(entry)
+	}
+
+	delete(s.entries, key)
+
+	return len(variants)
+}
+
+func (s *memoryShard) stats(stats StoreStats) StoreStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, variants := range s.entries {
+		for _, entry := range variants {
+			stats.Entries++
+			stats.Bytes += int64(len(entry.respBody))
+		}
+	}
+
+	return stats
+}
+
+// yieldStoreEntries sorts the given entries by key and variant and passes them to yield.
+func yieldStoreEntries(entries []StoreEntry, yield func(StoreEntry, error) bool) {
+	slices.SortFunc(entries, func(a, b StoreEntry) int {
+		return cmp.Or(strings.Compare(a.Key, b.Key), strings.Compare(a.Variant, b.Variant))
+	})
+
+	for _, entry := range entries {
+		if !yield(entry, nil) {
+			return
+		}
+	}
+}
+
+// ShardedMemoryStore is a [Store] that stores responses in memory, like [MemoryStore], but splits the stored responses
+// into multiple independently locked shards, so that storing a response only blocks operations on the same shard.
+//
+// Locks are only held while looking up or replacing the stored responses for a request. Matching the request against
+// the Vary header of the stored responses and copying the response happens without holding any locks. Response bodies
+// are never modified after being stored and are shared between all returned responses.
+//
+// There is no limit to the number of stored responses and expired responses are never removed.
+//
+// All responses of a partition (see [PartitionHeader]) are stored in the same shard.
+//
+// ShardedMemoryStore implements the optional [StoreLister], [StoreDeleter], [StoreStatsReporter], [TagPurger],
+// [PartitionPurger] and [URLPurger] interfaces.
+//
+// The zero value is ready to use.
+type ShardedMemoryStore struct {
+	// Clock is used to calculate the age of stored responses.
+	//
+	// If nil, [time.Now] is used.
+	Clock Clock
+
+	// Shards is the number of shards.
+	//
+	// If zero, 4 times the value of [runtime.GOMAXPROCS] at the time of first use is used.
+	//
+	// Changing Shards after the store was first used has no effect.
+	Shards int
+
+	// MaxPartitionEntries, if greater than zero, limits the number of responses stored per partition. When the limit
+	// is exceeded, the oldest responses of the partition are removed.
+	MaxPartitionEntries int
+
+	initOnce sync.Once
+	seed     maphash.Seed
+	shards   []memoryShard
+}
+
+// NewShardedMemoryStore returns a new [ShardedMemoryStore] with the given number of shards that uses [time.Now] for
+// all age calculations.
+//
+// If shards is zero, the default number of shards is used. See [ShardedMemoryStore.Shards] for details.
+func NewShardedMemoryStore(shards int) *ShardedMemoryStore {
+	return &ShardedMemoryStore{Shards: shards}
+}
+
+func (m *ShardedMemoryStore) init() {
+	n := m.Shards
+	if n <= 0 {
+		n = 4 * runtime.GOMAXPROCS(0)
+	}
+
+	m.seed = maphash.MakeSeed()
+	m.shards = make([]memoryShard, n)
+}
+
+// shard returns the shard for the given partition or, if the partition is empty, for the given key.
+func (m *ShardedMemoryStore) shard(partition, key string) *memoryShard {
+	m.initOnce.Do(m.init)
+
+	if partition != "" {
+		key = partition
+	}
+
+	return &m.shards[maphash.String(m.seed, key)%uint64(len(m.shards))]
+}
+
+// Get implements the [Store] interface.
+func (m *ShardedMemoryStore) Get(_ context.Context, req *http.Request) (*http.Response, error) {
+	key := memoryStoreKey(req)
+
+	return m.shard(req.Header.Get(PartitionHeader), key).get(key, req, nowFrom(m.Clock)), nil
+}
+
+// Set implements the [Store] interface.
+func (m *ShardedMemoryStore) Set(
+	_ context.Context,
+	req *http.Request, reqTime time.Time,
+	resp *http.Response, respTime time.Time,
+) error {
+	entry, err := newMemoryStoreEntry(req, reqTime, resp, respTime)
+	if entry == nil || err != nil {
+		return err
+	}
+
+	m.shard(entry.partition, entry.key).set(entry, m.MaxPartitionEntries)
+
+	return nil
+}
+
+// PurgeTags implements the [TagPurger] interface.
+func (m *ShardedMemoryStore) PurgeTags(_ context.Context, mode PurgeMode, tags ...string) (int, error) {
+	m.initOnce.Do(m.init)
+
+	var n int
+
+	for i := range m.shards {
+		n += m.shards[i].purgeTags(mode, tags)
+	}
+
+	return n, nil
+}
+
+// Entries implements the [StoreLister] interface.
+func (m *ShardedMemoryStore) Entries(context.Context) iter.Seq2[StoreEntry, error] {
+	return func(yield func(StoreEntry, error) bool) {
+		m.initOnce.Do(m.init)
+
+		now := nowFrom(m.Clock)
+
+		var entries []StoreEntry
+
+		for i := range m.shards {
+			entries = m.shards[i].appendEntries(entries, now)
+		}
+
+		yieldStoreEntries(entries, yield)
+	}
+}
+
+// Delete implements the [StoreDeleter] interface.
+//
+// Since the shard of a partitioned response can not be derived from its key, all shards are checked.
+func (m *ShardedMemoryStore) Delete(_ context.Context, key string) (int, error) {
+	m.initOnce.Do(m.init)
+
+	var n int
+
+	for i := range m.shards {
+		n += m.shards[i].delete(key)
+	}
+
+	return n, nil
+}
+
+// DeleteRequest implements the [StoreDeleter] interface.
+func (m *ShardedMemoryStore) DeleteRequest(_ context.Context, req *http.Request) (int, error) {
+	key := memoryStoreKey(req)
+
+	return m.shard(req.Header.Get(PartitionHeader), key).delete(key), nil
+}
+
+// PurgeURL implements the [URLPurger] interface.
+//
+// Since the partitioned responses for the URL can be stored in any shard, all shards are checked.
+func (m *ShardedMemoryStore) PurgeURL(_ context.Context, method, url string) (int, error) {
+	m.initOnce.Do(m.init)
+
+	key := memoryKey(method, url, "")
+
+	var n int
+
+	for i := range m.shards {
+		n += m.shards[i].purgeURL(key)
+	}
+
+	return n, nil
+}
+
+// PurgePartition implements the [PartitionPurger] interface.
+func (m *ShardedMemoryStore) PurgePartition(_ context.Context, partition string) (int, error) {
+	if partition == "" {
+		return 0, nil
+	}
+
+	return m.shard(partition, "").purgePartition(partition), nil
+}
+
+// Stats implements the [StoreStatsReporter] interface.
+func (m *ShardedMemoryStore) Stats(context.Context) (StoreStats, error) {
+	m.initOnce.Do(m.init)
+
+	var stats StoreStats
+
+	for i := range m.shards {
+		stats = m.shards[i].stats(stats)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,152 @@
+package httpcache_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nussjustin/httpcache"
+	"github.com/nussjustin/httpcache/storetest"
+)
+
+func TestShardedMemoryStore(t *testing.T) {
+	for _, shards := range []int{0, 1, 7} {
+		t.Run(fmt.Sprintf("shards=%d", shards), func(t *testing.T) {
+			storetest.Run(t, func() httpcache.Store { return httpcache.NewShardedMemoryStore(shards) })
+		})
+	}
+}
+
+func TestShardedMemoryStore_SoftPurge(t *testing.T) {
+	store := httpcache.NewShardedMemoryStore(4)
+
+	req := newReq()
+
+	if err := store.Set(t.Context(), req, time.Now(), newResp(withRespHeader(httpcache.TagsHeader, "a")), time.Now()); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// Responses returned before the purge must not be affected by it.
+	before, _ := store.Get(t.Context(), req)
+
+	if n, err := store.PurgeTags(t.Context(), httpcache.PurgeSoft, "a"); n != 1 || err != nil {
+		t.Fatalf("PurgeTags() = %d, %v, want 1, nil", n, err)
+	}
+
+	after, _ := store.Get(t.Context(), req)
+
+	if got, want := before.Header.Get("Age"), "0"; got != want {
+		t.Errorf("got Age %q before purge, want %q", got, want)
+	}
+
+	if got, want := after.Header.Get("Age"), "2147483648"; got != want {
+		t.Errorf("got Age %q after purge, want %q", got, want)
+	}
+}
+
+// BenchmarkStore_Get compares the stores under concurrent load.
+//
+// Use the -cpu flag to see how the stores scale with GOMAXPROCS, for example:
+//
+//	go test -run '^$' -bench BenchmarkStore_Get -cpu 1,2,4,8,16
+func BenchmarkStore_Get(b *testing.B) {
+	benchmarkStore(b, func(b *testing.B, store httpcache.Store, reqs []*http.Request) {
+		var next atomic.Uint64
+
+		b.RunParallel(func(pb *testing.PB) {
+			for i := next.Add(1); pb.Next(); i++ {
+				resp, err := store.Get(context.Background(), reqs[i%uint64(len(reqs))])
+				if err != nil || resp == nil {
+					b.Errorf("Get() = %v, %v", resp, err)
+					return
+				}
+			}
+		})
+	})
+}
+
+// BenchmarkStore_Set is like [BenchmarkStore_Get], but only replaces stored responses. Since writes need an exclusive
+// lock, this shows the difference between a single lock and multiple shards most clearly.
+func BenchmarkStore_Set(b *testing.B) {
+	benchmarkStore(b, func(b *testing.B, store httpcache.Store, reqs []*http.Request) {
+		var next atomic.Uint64
+
+		b.RunParallel(func(pb *testing.PB) {
+			for i := next.Add(1); pb.Next(); i++ {
+				req := reqs[i%uint64(len(reqs))]
+
+				if err := store.Set(context.Background(), req, time.Now(), newBenchmarkResp(), time.Now()); err != nil {
+					b.Errorf("Set() error = %v", err)
+					return
+				}
+			}
+		})
+	})
+}
+
+// BenchmarkStore_GetSet is like [BenchmarkStore_Get], but replaces the stored response on every 10th iteration.
+func BenchmarkStore_GetSet(b *testing.B) {
+	benchmarkStore(b, func(b *testing.B, store httpcache.Store, reqs []*http.Request) {
+		var next atomic.Uint64
+
+		b.RunParallel(func(pb *testing.PB) {
+			for i := next.Add(1); pb.Next(); i++ {
+				req := reqs[i%uint64(len(reqs))]
+
+				if i%10 == 0 {
+					if err := store.Set(context.Background(), req, time.Now(), newBenchmarkResp(), time.Now()); err != nil {
+						b.Errorf("Set() error = %v", err)
+						return
+					}
+					continue
+				}
+
+				if _, err := store.Get(context.Background(), req); err != nil {
+					b.Errorf("Get() error = %v", err)
+					return
+				}
+			}
+		})
+	})
+}
+
+func benchmarkStore(b *testing.B, f func(b *testing.B, store httpcache.Store, reqs []*http.Request)) {
+	stores := []struct {
+		name     string
+		newStore func() httpcache.Store
+	}{
+		{"MemoryStore", func() httpcache.Store { return httpcache.NewMemoryStore() }},
+		{"ShardedMemoryStore", func() httpcache.Store { return httpcache.NewShardedMemoryStore(0) }},
+	}
+
+	for _, s := range stores {
+		b.Run(s.name, func(b *testing.B) {
+			store := s.newStore()
+
+			reqs := make([]*http.Request, 1024)
+
+			for i := range reqs {
+				reqs[i] = newReq(
+					withReqUrl(fmt.Sprintf("http://example.com/%d", i)),
+					withReqHeader("Accept-Encoding", "gzip"))
+
+				if err := store.Set(b.Context(), reqs[i], time.Now(), newBenchmarkResp(), time.Now()); err != nil {
+					b.Fatalf("Set() error = %v", err)
+				}
+			}
+
+			f(b, store, reqs)
+		})
+	}
+}
+
+func newBenchmarkResp() *http.Response {
+	return newResp(
+		withRespHeader("Cache-Control", "max-age=60"),
+		withRespHeader("Vary", "Accept-Encoding"),
+		withRespBody(strings.NewReader(strings.Repeat("x", 1024))))
+}
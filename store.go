@@ -0,0 +1,326 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// StoreEntry contains metadata about a single response stored in a [Store].
+type StoreEntry struct {
+	// Key identifies the stored responses for a request in the store. All variants of a response share the same key.
+	//
+	// The format of the key is specific to the store.
+	Key string
+
+	// Partition is the partition of the stored response, as given by the [PartitionHeader] request header. It is empty
+	// if the response is not partitioned.
+	Partition string
+
+	// Method is the method of the request used to store the response.
+	Method string
+
+	// URL is the URL of the request used to store the response.
+	URL string
+
+	// Variant identifies the variant of the response, based on the request headers listed in the Vary header of the
+	// response. It is empty if the response has no Vary header.
+	//
+	// The format of the variant is specific to the store, but it must consist of printable characters only, so that it
+	// can be displayed, for example by the handler returned by [NewAdminHandler]. [MemoryStore] and
+	// [ShardedMemoryStore] use the hex encoded result of [Vary.Key].
+	Variant string
+
+	// StatusCode is the status code of the stored response.
+	StatusCode int
+
+	// Header contains the headers of the stored response, without the Age header.
+	Header http.Header
+
+	// Size is the size of the stored response body in bytes.
+	Size int64
+
+	// Age is the current age of the stored response, as calculated by [CalculateAge].
+	Age time.Duration
+}
+
+// StoreLister is an optional interface that can be implemented by a [Store] to support listing the stored responses.
+type StoreLister interface {
+	// Entries returns an iterator over all stored responses.
+	//
+	// Implementations must not load the response bodies and must allow calling other methods of the store while
+	// iterating.
+	Entries(ctx context.Context) iter.Seq2[StoreEntry, error]
+}
+
+// StoreDeleter is an optional interface that can be implemented by a [Store] to support deleting stored responses.
+type StoreDeleter interface {
+	// Delete removes all variants of the stored response with the given key and returns the number of removed
+	// responses.
+	//
+	// Deleting a key that does not exist is not an error.
+	Delete(ctx context.Context, key string) (int, error)
+
+	// DeleteRequest removes all variants of the stored response for the given request and returns the number of
+	// removed responses.
+	//
+	// The given request must not be modified.
+	//
+	// Deleting responses that do not exist is not an error.
+	DeleteRequest(ctx context.Context, req *http.Request) (int, error)
+}
+
+// URLPurger is an optional interface that can be implemented by a [Store] to support removing the stored responses for
+// a URL in all partitions at once, without having to list all stored responses.
+//
+// If implemented, [Client] uses it to invalidate stored responses after unsafe requests.
+type URLPurger interface {
+	// PurgeURL removes all variants of the stored responses for the given method and URL in all partitions, including
+	// responses that are not partitioned, and returns the number of removed responses.
+	//
+	// Purging responses that do not exist is not an error.
+	PurgeURL(ctx context.Context, method, url string) (int, error)
+}
+
+// StoreStats contains aggregate statistics about a [Store].
+type StoreStats struct {
+	// Entries is the number of stored responses, counting each variant separately.
+	Entries int
+
+	// Bytes is the total size of all stored response bodies in bytes.
+	Bytes int64
+}
+
+// StoreStatsReporter is an optional interface that can be implemented by a [Store] to report statistics without
+// having to iterate over all entries.
+type StoreStatsReporter interface {
+	// Stats returns the current statistics for the store.
+	Stats(ctx context.Context) (StoreStats, error)
+}
+
+var errDeleteUnsupported = fmt.Errorf("store does not implement StoreDeleter: %w", errors.ErrUnsupported)
+
+var errPurgeURLUnsupported = fmt.Errorf("store does not implement URLPurger: %w", errors.ErrUnsupported)
+
+var errStatsUnsupported = fmt.Errorf("store does not implement StoreStatsReporter or StoreLister: %w",
+	errors.ErrUnsupported)
+
+// Delete removes all stored responses for the given request and returns the number of removed responses.
+//
+// If the [Store] does not implement [StoreDeleter], an error matching [errors.ErrUnsupported] is returned.
+func (c *Client) Delete(ctx context.Context, req *http.Request) (int, error) {
+	deleter, ok := c.Store.(StoreDeleter)
+	if !ok {
+		return 0, errDeleteUnsupported
+	}
+
+	n, err := deleter.DeleteRequest(ctx, c.partition(normalize(c.config(), req)))
+
+	if n > 0 || err != nil {
+		c.observe(ctx, nopSpan{}, Event{Type: EventInvalidated, Request: req, Reason: "delete", Err: err})
+	}
+
+	return n, err
+}
+
+// Stats returns statistics about the [Store].
+//
+// If the store does not implement [StoreStatsReporter], the statistics are calculated by iterating over all entries
+// using [StoreLister]. If the store implements neither interface, an error matching [errors.ErrUnsupported] is
+// returned.
+func (c *Client) Stats(ctx context.Context) (StoreStats, error) {
+	if reporter, ok := c.Store.(StoreStatsReporter); ok {
+		return reporter.Stats(ctx)
+	}
+
+	lister, ok := c.Store.(StoreLister)
+	if !ok {
+		return StoreStats{}, errStatsUnsupported
+	}
+
+	return statsFromEntries(lister.Entries(ctx))
+}
+
+// statsFromEntries calculates the statistics for the given entries.
+func statsFromEntries(entries iter.Seq2[StoreEntry, error]) (StoreStats, error) {
+	var stats StoreStats
+
+	for e, err := range entries {
+		if err != nil {
+			return StoreStats{}, err
+		}
+
+		stats.Entries++
+		stats.Bytes += e.Size
+	}
+
+	return stats, nil
+}
+
+// invalidate removes the stored responses for the target URI of an unsafe request, if the [Store] implements
+// [URLPurger] or [StoreDeleter].
+//
+// From https://www.rfc-editor.org/rfc/rfc9111#name-invalidating-stored-respons
+//
+// Because unsafe request methods (Section 9.2.1 of [HTTP]) such as PUT, POST, or DELETE have the potential for
+// changing state on the origin server, intervening caches are required to invalidate stored responses to keep their
+// contents up to date.
+//
+// A cache MUST invalidate the target URI (Section 7.1 of [HTTP]) when it receives a non-error status code in response
+// to an unsafe request method (including methods whose safety is unknown).
+//
+// If the [Store] implements [URLPurger], the responses in all partitions are invalidated using it. Otherwise, see
+// [Client.deleteTargets].
+func (c *Client) invalidate(ctx context.Context, span Span, config *Config, req *http.Request, resp *http.Response) {
+	if isSafeMethod(req.Method) || resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return
+	}
+
+	methods := config.SupportedRequestMethods
+	if methods == nil {
+		methods = DefaultSupportedRequestMethods
+	}
+
+	normalized := normalize(config, req)
+
+	n, err := purgeURL(ctx, c.Store, normalized.URL.String(), methods)
+	if errors.Is(err, errors.ErrUnsupported) {
+		n, err = c.deleteTargets(ctx, normalized, methods)
+	}
+
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		// Wrapping stores like TieredStore may report that none of the wrapped stores supports deletion.
+	case err != nil:
+		span.RecordError(err)
+		c.observe(ctx, span, Event{Type: EventInvalidated, Request: req, Response: resp, Reason: "unsafe-method", Err: err})
+	case n > 0:
+		c.observe(ctx, span, Event{Type: EventInvalidated, Request: req, Response: resp, Reason: "unsafe-method"})
+	}
+}
+
+// purgeURL removes the stored responses for the given URL and methods using [URLPurger] and returns the number of
+// removed responses.
+//
+// If the store does not implement [URLPurger], an error matching [errors.ErrUnsupported] is returned.
+func purgeURL(ctx context.Context, store Store, url string, methods []string) (int, error) {
+	purger, ok := store.(URLPurger)
+	if !ok {
+		return 0, errPurgeURLUnsupported
+	}
+
+	var total int
+
+	for _, method := range methods {
+		n, err := purger.PurgeURL(ctx, method, url)
+		total += n
+
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// deleteTargets removes the stored responses for the URL of the given request and the given methods using
+// [StoreDeleter] and returns the number of removed responses.
+//
+// If [Client.Partition] is set, the unpartitioned responses and the responses in the partition of the request are
+// removed. Responses in other partitions are only removed if the [Store] also implements [StoreLister], which requires
+// listing all stored responses.
+func (c *Client) deleteTargets(ctx context.Context, req *http.Request, methods []string) (int, error) {
+	deleter, ok := c.Store.(StoreDeleter)
+	if !ok {
+		return 0, errDeleteUnsupported
+	}
+
+	partitioned := c.partition(req)
+
+	var total int
+
+	for _, method := range methods {
+		target := req.Clone(ctx)
+		target.Method = method
+		target.Body = nil
+		target.Header.Del(PartitionHeader)
+
+		targets := []*http.Request{target}
+
+		if partition := partitioned.Header.Get(PartitionHeader); partition != "" {
+			target = target.Clone(ctx)
+			target.Header.Set(PartitionHeader, partition)
+
+			targets = append(targets, target)
+		}
+
+		for _, target := range targets {
+			n, err := deleter.DeleteRequest(ctx, target)
+			total += n
+
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+
+	if lister, ok := c.Store.(StoreLister); ok && c.Partition != nil {
+		n, err := invalidatePartitions(ctx, lister, deleter, req.URL.String(), methods)
+		total += n
+
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// invalidatePartitions removes the partitioned responses for the given URL and methods in all partitions, as listed by
+// the store, and returns the number of removed responses.
+func invalidatePartitions(
+	ctx context.Context,
+	lister StoreLister,
+	deleter StoreDeleter,
+	url string,
+	methods []string,
+) (int, error) {
+	var keys []string
+
+	for e, err := range lister.Entries(ctx) {
+		if err != nil {
+			return 0, err
+		}
+
+		if e.Partition != "" && e.URL == url && slices.Contains(methods, e.Method) && !slices.Contains(keys, e.Key) {
+			keys = append(keys, e.Key)
+		}
+	}
+
+	var total int
+
+	for _, key := range keys {
+		n, err := deleter.Delete(ctx, key)
+		total += n
+
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// isSafeMethod returns true for the request methods defined as safe in RFC 9110, Section 9.2.1, as well as for QUERY.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, "QUERY":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,156 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nussjustin/httpcache"
+)
+
+type listOnlyStore struct {
+	httpcache.Store
+	entries []httpcache.StoreEntry
+}
+
+func (l *listOnlyStore) Entries(context.Context) iter.Seq2[httpcache.StoreEntry, error] {
+	return func(yield func(httpcache.StoreEntry, error) bool) {
+		for _, e := range l.entries {
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestClient_Stats(t *testing.T) {
+	store := httpcache.NewMemoryStore()
+
+	client := &httpcache.Client{Store: store}
+
+	if err := store.Set(t.Context(), newReq(), time.Now(), newResp(withRespBody(strings.NewReader("body"))), time.Now()); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	stats, err := client.Stats(t.Context())
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+
+	if want := (httpcache.StoreStats{Entries: 1, Bytes: 4}); stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+
+	client.Store = &listOnlyStore{entries: []httpcache.StoreEntry{{Size: 2}, {Size: 3}}}
+
+	stats, err = client.Stats(t.Context())
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+
+	if want := (httpcache.StoreStats{Entries: 2, Bytes: 5}); stats != want {
+		t.Errorf("Stats() using StoreLister = %+v, want %+v", stats, want)
+	}
+
+	client.Store = &trackingStore{Store: store}
+
+	if _, err := client.Stats(t.Context()); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Stats() error = %v, want %v", err, errors.ErrUnsupported)
+	}
+}
+
+func TestClient_Delete(t *testing.T) {
+	store := httpcache.NewMemoryStore()
+
+	observer := &recordingObserver{}
+
+	client := &httpcache.Client{Store: store, Observer: observer}
+
+	if err := store.Set(t.Context(), newReq(), time.Now(), newResp(), time.Now()); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	n, err := client.Delete(t.Context(), newReq())
+	if err != nil || n != 1 {
+		t.Errorf("Delete() = %d, %v, want 1, nil", n, err)
+	}
+
+	if got, want := observer.types(), []httpcache.EventType{httpcache.EventInvalidated}; !slices.Equal(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+
+	client.Store = &trackingStore{Store: store}
+
+	if _, err := client.Delete(t.Context(), newReq()); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Delete() error = %v, want %v", err, errors.ErrUnsupported)
+	}
+}
+
+func TestClient_Do_InvalidatesOnUnsafeMethod(t *testing.T) {
+	var status int
+
+	client := &httpcache.Client{
+		Store: httpcache.NewMemoryStore(),
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				resp := newResp(withRespHeader("Cache-Control", "max-age=60"))
+				if req.Method != http.MethodGet {
+					resp = newResp(withRespStatus(status), withRespHeader("Cache-Control", "no-store"))
+				}
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	tests := []struct {
+		method      string
+		status      int
+		bypass      bool
+		supported   bool
+		invalidated bool
+	}{
+		{method: http.MethodPost, status: http.StatusOK, invalidated: true},
+		{method: http.MethodPost, status: http.StatusOK, bypass: true, invalidated: true},
+		{method: http.MethodPost, status: http.StatusOK, supported: true, invalidated: true},
+		{method: http.MethodDelete, status: http.StatusNoContent, invalidated: true},
+		{method: http.MethodPut, status: http.StatusInternalServerError, invalidated: false},
+		{method: http.MethodOptions, status: http.StatusOK, invalidated: false},
+	}
+	for _, tt := range tests {
+		client.Config.SupportedRequestMethods = nil
+
+		if _, err := client.Do(newReq()); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+
+		status = tt.status
+
+		if tt.supported {
+			client.Config.SupportedRequestMethods = []string{http.MethodGet, tt.method}
+		}
+
+		opts := []reqOpt{withReqMethod(tt.method)}
+		if tt.bypass {
+			opts = append(opts, withRequestPolicy(httpcache.RequestPolicy{Bypass: true}))
+		}
+
+		if _, err := client.Do(newReq(opts...)); err != nil {
+			t.Fatalf("Do(%s) error = %v", tt.method, err)
+		}
+
+		stats, err := client.Stats(t.Context())
+		if err != nil {
+			t.Fatalf("Stats() error = %v", err)
+		}
+
+		if got := stats.Entries == 0; got != tt.invalidated {
+			t.Errorf("%s with status %d: invalidated = %v, want %v", tt.method, tt.status, got, tt.invalidated)
+		}
+	}
+}
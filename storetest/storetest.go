@@ -0,0 +1,832 @@
+// Package storetest implements a conformance test suite for implementations of [httpcache.Store].
+package storetest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nussjustin/httpcache"
+)
+
+// Run tests that the stores returned by newStore behave as documented by [httpcache.Store].
+//
+// newStore is called once for each test and must return a new, empty store each time.
+//
+// The tests check the handling of Vary, including the replacement of variants and the Vary wildcard, the separation of
+// partitions given by [httpcache.PartitionHeader], the Age header returned by [httpcache.Store.Get], that requests are
+// not modified, that response bodies are not shared between readers, that trailers are stored and that the store is
+// safe for concurrent use. Run the tests with -race to detect data races.
+//
+// If a store implements any of the optional interfaces [httpcache.StoreLister], [httpcache.StoreDeleter],
+// [httpcache.StoreStatsReporter], [httpcache.TagPurger], [httpcache.PartitionPurger] or [httpcache.URLPurger], these
+// are tested as well.
+//
+// Stores are expected to use the current time when calculating the age of responses. Ages are checked with a tolerance
+// of a few seconds to allow for slow stores.
+func Run(t *testing.T, newStore func() httpcache.Store) {
+	t.Helper()
+
+	t.Run("Get", func(t *testing.T) { testGet(t, newStore) })
+	t.Run("Vary", func(t *testing.T) { testVary(t, newStore) })
+	t.Run("Partition", func(t *testing.T) { testPartition(t, newStore) })
+	t.Run("Age", func(t *testing.T) { testAge(t, newStore) })
+	t.Run("RequestNotModified", func(t *testing.T) { testRequestNotModified(t, newStore) })
+	t.Run("BodyIsolation", func(t *testing.T) { testBodyIsolation(t, newStore) })
+	t.Run("Trailer", func(t *testing.T) { testTrailer(t, newStore) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, newStore) })
+	t.Run("StoreLister", func(t *testing.T) { testStoreLister(t, newStore) })
+	t.Run("StoreStatsReporter", func(t *testing.T) { testStoreStatsReporter(t, newStore) })
+	t.Run("StoreDeleter", func(t *testing.T) { testStoreDeleter(t, newStore) })
+	t.Run("TagPurger", func(t *testing.T) { testTagPurger(t, newStore) })
+	t.Run("PartitionPurger", func(t *testing.T) { testPartitionPurger(t, newStore) })
+	t.Run("URLPurger", func(t *testing.T) { testURLPurger(t, newStore) })
+}
+
+// ageTolerance is the maximum difference allowed between the expected and the actual age of a response.
+const ageTolerance = 5 * time.Second
+
+func newRequest(method, rawURL string, header ...string) *http.Request {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+
+	req := &http.Request{
+		Method:     method,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+
+	for i := 0; i < len(header); i += 2 {
+		req.Header.Add(header[i], header[i+1])
+	}
+
+	return req
+}
+
+func newResponse(body string, header ...string) *http.Response {
+	resp := &http.Response{
+		Status:        http.StatusText(http.StatusOK),
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+
+	for i := 0; i < len(header); i += 2 {
+		resp.Header.Add(header[i], header[i+1])
+	}
+
+	return resp
+}
+
+func set(t *testing.T, store httpcache.Store, req *http.Request, resp *http.Response, respTime time.Time) {
+	t.Helper()
+
+	if err := store.Set(t.Context(), req, respTime, resp, respTime); err != nil {
+		t.Fatalf("Set(%s %s) error = %v", req.Method, req.URL, err)
+	}
+}
+
+func get(t *testing.T, store httpcache.Store, req *http.Request) *http.Response {
+	t.Helper()
+
+	resp, err := store.Get(t.Context(), req)
+	if err != nil {
+		t.Fatalf("Get(%s %s) error = %v", req.Method, req.URL, err)
+	}
+
+	return resp
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response body: %v", err)
+	}
+
+	if err := resp.Body.Close(); err != nil {
+		t.Errorf("failed to close response body: %v", err)
+	}
+
+	return string(b)
+}
+
+func checkAge(t *testing.T, resp *http.Response, want time.Duration) {
+	t.Helper()
+
+	s := resp.Header.Get("Age")
+	if s == "" {
+		t.Errorf("Get() returned response without Age header")
+		return
+	}
+
+	got, err := httpcache.ParseAge(s)
+	if err != nil {
+		t.Errorf("Get() returned invalid Age header %q: %v", s, err)
+		return
+	}
+
+	if got < want || got > want+ageTolerance {
+		t.Errorf("Get() returned Age %s, want %s", got, want)
+	}
+}
+
+func testGet(t *testing.T, newStore func() httpcache.Store) {
+	store := newStore()
+
+	set(t, store,
+		newRequest("GET", "http://example.com/"),
+		newResponse("body", "Cache-Control", "max-age=60", "X-Test", "value"),
+		time.Now())
+
+	resp := get(t, store, newRequest("GET", "http://example.com/"))
+	if resp == nil {
+		t.Fatalf("Get() returned no response")
+	}
+
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("Get() Response.StatusCode = %d, want %d", got, want)
+	}
+
+	if got, want := resp.Header.Get("X-Test"), "value"; got != want {
+		t.Errorf("Get() Response.Header[X-Test] = %q, want %q", got, want)
+	}
+
+	if got, want := readBody(t, resp), "body"; got != want {
+		t.Errorf("Get() Response.Body = %q, want %q", got, want)
+	}
+
+	for _, req := range []*http.Request{
+		newRequest("HEAD", "http://example.com/"),
+		newRequest("GET", "https://example.com/"),
+		newRequest("GET", "http://example.com/other"),
+		newRequest("GET", "http://example.com/?query"),
+	} {
+		if resp := get(t, store, req); resp != nil {
+			t.Errorf("Get(%s %s) returned response stored for GET http://example.com/", req.Method, req.URL)
+		}
+	}
+}
+
+func testVary(t *testing.T, newStore func() httpcache.Store) {
+	t.Run("Match", func(t *testing.T) {
+		store := newStore()
+
+		set(t, store,
+			newRequest("GET", "http://example.com/", "Header-1", "Value-1", "Header-2", "Value-2", "Header-3", "Value-3"),
+			newResponse("body", "Vary", "Header-1, Header-2"),
+			time.Now())
+
+		if resp := get(t, store, newRequest("GET", "http://example.com/",
+			"Header-1", "Value-1", "Header-2", "Value-2", "Header-3", "Changed")); resp == nil {
+			t.Errorf("Get() returned no response for matching request")
+		}
+
+		if resp := get(t, store, newRequest("GET", "http://example.com/",
+			"Header-1", "Value-1", "Header-2", "Changed", "Header-3", "Value-3")); resp != nil {
+			t.Errorf("Get() returned response for request with mismatched header")
+		}
+
+		if resp := get(t, store, newRequest("GET", "http://example.com/", "Header-1", "Value-1")); resp != nil {
+			t.Errorf("Get() returned response for request with missing header")
+		}
+	})
+
+	t.Run("Variants", func(t *testing.T) {
+		store := newStore()
+
+		for _, lang := range []string{"de", "en"} {
+			set(t, store,
+				newRequest("GET", "http://example.com/", "Accept-Language", lang),
+				newResponse(lang+"-1", "Vary", "Accept-Language"),
+				time.Now())
+		}
+
+		set(t, store,
+			newRequest("GET", "http://example.com/", "Accept-Language", "en"),
+			newResponse("en-2", "Vary", "Accept-Language"),
+			time.Now())
+
+		for lang, want := range map[string]string{"de": "de-1", "en": "en-2"} {
+			resp := get(t, store, newRequest("GET", "http://example.com/", "Accept-Language", lang))
+			if resp == nil {
+				t.Errorf("Get() returned no response for variant %q", lang)
+				continue
+			}
+
+			if got := readBody(t, resp); got != want {
+				t.Errorf("Get() returned body %q for variant %q, want %q", got, lang, want)
+			}
+		}
+	})
+
+	t.Run("Wildcard", func(t *testing.T) {
+		store := newStore()
+
+		set(t, store,
+			newRequest("GET", "http://example.com/"),
+			newResponse("body", "Vary", "*"),
+			time.Now())
+
+		if resp := get(t, store, newRequest("GET", "http://example.com/")); resp != nil {
+			t.Errorf("Get() returned response with Vary: *")
+		}
+	})
+}
+
+func testAge(t *testing.T, newStore func() httpcache.Store) {
+	tests := []struct {
+		name   string
+		header []string
+		want   time.Duration
+	}{
+		{
+			name: "RespTime",
+			want: time.Minute,
+		},
+		{
+			name:   "AgeHeader",
+			header: []string{"Age", "10"},
+			want:   70 * time.Second,
+		},
+		{
+			name:   "DateInThePast",
+			header: []string{"Date", time.Now().Add(-90 * time.Second).UTC().Format(http.TimeFormat)},
+			want:   90 * time.Second,
+		},
+		{
+			name:   "InvalidDate",
+			header: []string{"Date", "invalid"},
+			want:   time.Minute,
+		},
+		{
+			name:   "Expired",
+			header: []string{"Cache-Control", "max-age=30"},
+			want:   time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newStore()
+
+			set(t, store,
+				newRequest("GET", "http://example.com/"),
+				newResponse("body", tt.header...),
+				time.Now().Add(-time.Minute))
+
+			resp := get(t, store, newRequest("GET", "http://example.com/"))
+			if resp == nil {
+				t.Fatalf("Get() returned no response")
+			}
+
+			checkAge(t, resp, tt.want)
+		})
+	}
+}
+
+func cloneRequest(req *http.Request) *http.Request {
+	c := *req
+	c.Header = req.Header.Clone()
+	u := *req.URL
+	c.URL = &u
+	return &c
+}
+
+func testRequestNotModified(t *testing.T, newStore func() httpcache.Store) {
+	store := newStore()
+
+	req := newRequest("GET", "http://example.com/", "Accept", "text/plain")
+	orig := cloneRequest(req)
+
+	set(t, store, req, newResponse("body", "Vary", "Accept"), time.Now())
+
+	if !reflect.DeepEqual(req, orig) {
+		t.Errorf("Set() modified request: got %#v, want %#v", req, orig)
+	}
+
+	_ = get(t, store, req)
+
+	if !reflect.DeepEqual(req, orig) {
+		t.Errorf("Get() modified request: got %#v, want %#v", req, orig)
+	}
+}
+
+// trailerBody sets the trailers of a response once the body was read, like bodies read by [http.Transport].
+type trailerBody struct {
+	io.Reader
+	resp    *http.Response
+	trailer http.Header
+}
+
+func (b *trailerBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		b.resp.Trailer = b.trailer
+	}
+	return n, err
+}
+
+func (b *trailerBody) Close() error {
+	return nil
+}
+
+func testTrailer(t *testing.T, newStore func() httpcache.Store) {
+	store := newStore()
+
+	resp := newResponse("body", "Cache-Control", "max-age=60", "Trailer", "X-Checksum")
+	resp.Body = &trailerBody{
+		Reader:  strings.NewReader("body"),
+		resp:    resp,
+		trailer: http.Header{"X-Checksum": {"abc"}},
+	}
+
+	set(t, store, newRequest("GET", "http://example.com/"), resp, time.Now())
+
+	resp = get(t, store, newRequest("GET", "http://example.com/"))
+	if resp == nil {
+		t.Fatalf("Get() returned no response")
+	}
+
+	if got, want := readBody(t, resp), "body"; got != want {
+		t.Errorf("Get() Response.Body = %q, want %q", got, want)
+	}
+
+	if got, want := resp.Trailer.Get("X-Checksum"), "abc"; got != want {
+		t.Errorf("Get() Response.Trailer[X-Checksum] = %q, want %q", got, want)
+	}
+}
+
+func testBodyIsolation(t *testing.T, newStore func() httpcache.Store) {
+	store := newStore()
+
+	body := strings.Repeat("0123456789", 1024)
+
+	set(t, store, newRequest("GET", "http://example.com/"), newResponse(body, "X-Test", "value"), time.Now())
+
+	first := get(t, store, newRequest("GET", "http://example.com/"))
+	second := get(t, store, newRequest("GET", "http://example.com/"))
+
+	if first == nil || second == nil {
+		t.Fatalf("Get() returned no response")
+	}
+
+	first.Header.Set("X-Test", "modified")
+
+	var wg sync.WaitGroup
+
+	bodies := make([]string, 2)
+
+	for i, resp := range []*http.Response{first, second} {
+		wg.Go(func() {
+			var buf bytes.Buffer
+
+			// Read in small chunks to interleave the readers
+			chunk := make([]byte, 7)
+			for {
+				n, err := resp.Body.Read(chunk)
+				buf.Write(chunk[:n])
+
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Errorf("failed to read body: %v", err)
+					return
+				}
+			}
+
+			bodies[i] = buf.String()
+		})
+	}
+
+	wg.Wait()
+
+	for i, got := range bodies {
+		if got != body {
+			t.Errorf("reader %d got body of length %d, want %d", i, len(got), len(body))
+		}
+	}
+
+	third := get(t, store, newRequest("GET", "http://example.com/"))
+	if third == nil {
+		t.Fatalf("Get() returned no response")
+	}
+
+	if got, want := third.Header.Get("X-Test"), "value"; got != want {
+		t.Errorf("Get() after modifying returned response got X-Test %q, want %q", got, want)
+	}
+
+	if got := readBody(t, third); got != body {
+		t.Errorf("Get() after reading returned body of length %d, want %d", len(got), len(body))
+	}
+}
+
+func testConcurrency(t *testing.T, newStore func() httpcache.Store) {
+	store := newStore()
+
+	const (
+		goroutines = 8
+		iterations = 50
+	)
+
+	var wg sync.WaitGroup
+
+	for g := range goroutines {
+		wg.Go(func() {
+			for i := range iterations {
+				u := "http://example.com/" + strconv.Itoa(i%5)
+				lang := strconv.Itoa(g % 2)
+
+				req := newRequest("GET", u, "Accept-Language", lang)
+
+				if err := store.Set(t.Context(), req, time.Now(), newResponse(u+lang, "Vary", "Accept-Language"), time.Now()); err != nil {
+					t.Errorf("Set() error = %v", err)
+					return
+				}
+
+				resp, err := store.Get(t.Context(), req)
+				if err != nil {
+					t.Errorf("Get() error = %v", err)
+					return
+				}
+
+				if resp == nil {
+					t.Errorf("Get() returned no response")
+					return
+				}
+
+				b, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Errorf("failed to read body: %v", err)
+					return
+				}
+
+				if string(b) != u+lang {
+					t.Errorf("Get() returned body %q, want %q", b, u+lang)
+				}
+			}
+		})
+	}
+
+	wg.Wait()
+}
+
+// populate stores two variants of http://example.com/a and one response for http://example.com/b, all with tags.
+func populate(t *testing.T, store httpcache.Store) {
+	t.Helper()
+
+	respTime := time.Now().Add(-10 * time.Second)
+
+	set(t, store,
+		newRequest("GET", "http://example.com/a", "Accept", "text/plain"),
+		newResponse("text", "Vary", "Accept", httpcache.TagsHeader, "a text"),
+		respTime)
+	set(t, store,
+		newRequest("GET", "http://example.com/a", "Accept", "text/html"),
+		newResponse("<p>html</p>", "Vary", "Accept", httpcache.TagsHeader, "a html"),
+		respTime)
+	set(t, store,
+		newRequest("GET", "http://example.com/b"),
+		newResponse("b", httpcache.TagsHeader, "b text"),
+		respTime)
+}
+
+func testStoreLister(t *testing.T, newStore func() httpcache.Store) {
+	store := newStore()
+
+	lister, ok := store.(httpcache.StoreLister)
+	if !ok {
+		t.Skip("store does not implement StoreLister")
+	}
+
+	populate(t, store)
+
+	var entries []httpcache.StoreEntry
+	for e, err := range lister.Entries(t.Context()) {
+		if err != nil {
+			t.Fatalf("Entries() error = %v", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if got, want := len(entries), 3; got != want {
+		t.Fatalf("Entries() returned %d entries, want %d", got, want)
+	}
+
+	keys := make(map[string][]string)
+	variants := make(map[string][]string)
+	sizes := make(map[string]int64)
+
+	for _, e := range entries {
+		keys[e.URL] = append(keys[e.URL], e.Key)
+		variants[e.URL] = append(variants[e.URL], e.Variant)
+		sizes[e.URL] += e.Size
+
+		if !utf8.ValidString(e.Variant) || strings.ContainsFunc(e.Variant, func(r rune) bool { return !unicode.IsPrint(r) }) {
+			t.Errorf("Entries() returned non-printable variant %q for %s", e.Variant, e.URL)
+		}
+
+		if got, want := e.Method, http.MethodGet; got != want {
+			t.Errorf("Entries() returned method %q, want %q", got, want)
+		}
+
+		if got, want := e.StatusCode, http.StatusOK; got != want {
+			t.Errorf("Entries() returned status code %d, want %d", got, want)
+		}
+
+		if e.Header.Get("Age") != "" {
+			t.Errorf("Entries() returned Age header %q for %s", e.Header.Get("Age"), e.URL)
+		}
+
+		if e.Age < 10*time.Second || e.Age > 10*time.Second+ageTolerance {
+			t.Errorf("Entries() returned age %s for %s, want %s", e.Age, e.URL, 10*time.Second)
+		}
+	}
+
+	if a := keys["http://example.com/a"]; len(a) != 2 || a[0] != a[1] {
+		t.Errorf("Entries() returned keys %q for variants, want two equal keys", a)
+	}
+
+	if a := variants["http://example.com/a"]; len(a) != 2 || a[0] == a[1] {
+		t.Errorf("Entries() returned variants %q, want two distinct variants", a)
+	}
+
+	if b := keys["http://example.com/b"]; len(b) != 1 || b[0] == keys["http://example.com/a"][0] {
+		t.Errorf("Entries() returned key %q for different URL, want distinct key", b)
+	}
+
+	if got, want := sizes["http://example.com/a"], int64(15); got != want {
+		t.Errorf("Entries() returned total size %d for variants, want %d", got, want)
+	}
+
+	for range lister.Entries(t.Context()) {
+		// Stopping early must not block or panic
+		break
+	}
+}
+
+func testStoreStatsReporter(t *testing.T, newStore func() httpcache.Store) {
+	store := newStore()
+
+	reporter, ok := store.(httpcache.StoreStatsReporter)
+	if !ok {
+		t.Skip("store does not implement StoreStatsReporter")
+	}
+
+	populate(t, store)
+
+	stats, err := reporter.Stats(t.Context())
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+
+	if want := (httpcache.StoreStats{Entries: 3, Bytes: 16}); stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+}
+
+func testStoreDeleter(t *testing.T, newStore func() httpcache.Store) {
+	store := newStore()
+
+	deleter, ok := store.(httpcache.StoreDeleter)
+	if !ok {
+		t.Skip("store does not implement StoreDeleter")
+	}
+
+	populate(t, store)
+
+	n, err := deleter.DeleteRequest(t.Context(), newRequest("GET", "http://example.com/a"))
+	if err != nil {
+		t.Fatalf("DeleteRequest() error = %v", err)
+	}
+
+	if got, want := n, 2; got != want {
+		t.Errorf("DeleteRequest() = %d, want %d", got, want)
+	}
+
+	for _, accept := range []string{"text/plain", "text/html"} {
+		if resp := get(t, store, newRequest("GET", "http://example.com/a", "Accept", accept)); resp != nil {
+			t.Errorf("Get() returned response for variant %q after DeleteRequest()", accept)
+		}
+	}
+
+	if resp := get(t, store, newRequest("GET", "http://example.com/b")); resp == nil {
+		t.Errorf("Get() returned no response for response that was not deleted")
+	}
+
+	n, err = deleter.DeleteRequest(t.Context(), newRequest("GET", "http://example.com/a"))
+	if err != nil || n != 0 {
+		t.Errorf("DeleteRequest() for deleted response = %d, %v, want 0, nil", n, err)
+	}
+
+	if lister, ok := store.(httpcache.StoreLister); ok {
+		for e, err := range lister.Entries(t.Context()) {
+			if err != nil {
+				t.Fatalf("Entries() error = %v", err)
+			}
+
+			n, err := deleter.Delete(t.Context(), e.Key)
+			if err != nil || n != 1 {
+				t.Errorf("Delete(%q) = %d, %v, want 1, nil", e.Key, n, err)
+			}
+		}
+
+		if resp := get(t, store, newRequest("GET", "http://example.com/b")); resp != nil {
+			t.Errorf("Get() returned response after Delete()")
+		}
+	}
+
+	n, err = deleter.Delete(t.Context(), "unknown")
+	if err != nil || n != 0 {
+		t.Errorf("Delete() for unknown key = %d, %v, want 0, nil", n, err)
+	}
+}
+
+func testTagPurger(t *testing.T, newStore func() httpcache.Store) {
+	store := newStore()
+
+	purger, ok := store.(httpcache.TagPurger)
+	if !ok {
+		t.Skip("store does not implement TagPurger")
+	}
+
+	populate(t, store)
+
+	n, err := purger.PurgeTags(t.Context(), httpcache.PurgeSoft, "html")
+	if err != nil || n != 1 {
+		t.Errorf("PurgeTags(soft) = %d, %v, want 1, nil", n, err)
+	}
+
+	resp := get(t, store, newRequest("GET", "http://example.com/a", "Accept", "text/html"))
+	if resp == nil {
+		t.Fatalf("Get() returned no response after soft purge")
+	}
+
+	if age, _ := httpcache.ParseAge(resp.Header.Get("Age")); age < 365*24*time.Hour {
+		t.Errorf("Get() after soft purge returned Age %s, want very large age", resp.Header.Get("Age"))
+	}
+
+	resp = get(t, store, newRequest("GET", "http://example.com/a", "Accept", "text/plain"))
+	if resp == nil {
+		t.Fatalf("Get() returned no response for variant that was not purged")
+	}
+
+	checkAge(t, resp, 10*time.Second)
+
+	n, err = purger.PurgeTags(t.Context(), httpcache.PurgeHard, "text")
+	if err != nil || n != 2 {
+		t.Errorf("PurgeTags(hard) = %d, %v, want 2, nil", n, err)
+	}
+
+	if resp := get(t, store, newRequest("GET", "http://example.com/b")); resp != nil {
+		t.Errorf("Get() returned response after hard purge")
+	}
+
+	if resp := get(t, store, newRequest("GET", "http://example.com/a", "Accept", "text/html")); resp == nil {
+		t.Errorf("Get() returned no response for variant that was not purged")
+	}
+}
+
+func populatePartitions(t *testing.T, store httpcache.Store) {
+	t.Helper()
+
+	for _, partition := range []string{"", "a", "b"} {
+		for _, path := range []string{"/x", "/y"} {
+			req := newRequest("GET", "http://example.com"+path)
+			if partition != "" {
+				req.Header.Set(httpcache.PartitionHeader, partition)
+			}
+
+			set(t, store, req, newResponse(partition+path, "Cache-Control", "max-age=60"), time.Now())
+		}
+	}
+}
+
+func testPartition(t *testing.T, newStore func() httpcache.Store) {
+	store := newStore()
+
+	populatePartitions(t, store)
+
+	for _, partition := range []string{"", "a", "b"} {
+		req := newRequest("GET", "http://example.com/x")
+		if partition != "" {
+			req.Header.Set(httpcache.PartitionHeader, partition)
+		}
+
+		resp := get(t, store, req)
+		if resp == nil {
+			t.Errorf("Get() returned no response for partition %q", partition)
+			continue
+		}
+
+		if got, want := readBody(t, resp), partition+"/x"; got != want {
+			t.Errorf("Get() for partition %q returned body %q, want %q", partition, got, want)
+		}
+	}
+
+	req := newRequest("GET", "http://example.com/x", httpcache.PartitionHeader, "c")
+	if resp := get(t, store, req); resp != nil {
+		t.Errorf("Get() returned response for unknown partition")
+	}
+}
+
+func testPartitionPurger(t *testing.T, newStore func() httpcache.Store) {
+	store := newStore()
+
+	purger, ok := store.(httpcache.PartitionPurger)
+	if !ok {
+		t.Skip("store does not implement PartitionPurger")
+	}
+
+	populatePartitions(t, store)
+
+	n, err := purger.PurgePartition(t.Context(), "a")
+	if err != nil || n != 2 {
+		t.Errorf("PurgePartition() = %d, %v, want 2, nil", n, err)
+	}
+
+	for _, partition := range []string{"", "a", "b"} {
+		for _, path := range []string{"/x", "/y"} {
+			req := newRequest("GET", "http://example.com"+path)
+			if partition != "" {
+				req.Header.Set(httpcache.PartitionHeader, partition)
+			}
+
+			if got, want := get(t, store, req) != nil, partition != "a"; got != want {
+				t.Errorf("Get(%s) for partition %q after PurgePartition() returned response = %v, want %v",
+					path, partition, got, want)
+			}
+		}
+	}
+
+	n, err = purger.PurgePartition(t.Context(), "a")
+	if err != nil || n != 0 {
+		t.Errorf("PurgePartition() for purged partition = %d, %v, want 0, nil", n, err)
+	}
+
+	n, err = purger.PurgePartition(t.Context(), "")
+	if err != nil || n != 0 {
+		t.Errorf("PurgePartition() for empty partition = %d, %v, want 0, nil", n, err)
+	}
+}
+
+func testURLPurger(t *testing.T, newStore func() httpcache.Store) {
+	store := newStore()
+
+	purger, ok := store.(httpcache.URLPurger)
+	if !ok {
+		t.Skip("store does not implement URLPurger")
+	}
+
+	populatePartitions(t, store)
+
+	set(t, store, newRequest("HEAD", "http://example.com/x"), newResponse("", "Cache-Control", "max-age=60"), time.Now())
+
+	n, err := purger.PurgeURL(t.Context(), "GET", "http://example.com/x")
+	if err != nil || n != 3 {
+		t.Errorf("PurgeURL() = %d, %v, want 3, nil", n, err)
+	}
+
+	for _, partition := range []string{"", "a", "b"} {
+		for _, path := range []string{"/x", "/y"} {
+			req := newRequest("GET", "http://example.com"+path)
+			if partition != "" {
+				req.Header.Set(httpcache.PartitionHeader, partition)
+			}
+
+			if got, want := get(t, store, req) != nil, path != "/x"; got != want {
+				t.Errorf("Get(%s) for partition %q after PurgeURL() returned response = %v, want %v",
+					path, partition, got, want)
+			}
+		}
+	}
+
+	if resp := get(t, store, newRequest("HEAD", "http://example.com/x")); resp == nil {
+		t.Errorf("Get() returned no response for method that was not purged")
+	}
+
+	n, err = purger.PurgeURL(t.Context(), "GET", "http://example.com/x")
+	if err != nil || n != 0 {
+		t.Errorf("PurgeURL() for purged URL = %d, %v, want 0, nil", n, err)
+	}
+}
@@ -0,0 +1,128 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// TagsHeader is the name of the header used to pass the tags of a response to a [Store].
+//
+// When storing a response, [Client] collects the tags from all headers listed in [Client.TagHeaders], removes those
+// headers and sets this header to the space separated list of tags.
+//
+// The header is removed from all responses returned by [Client.Do].
+const TagsHeader = "Httpcache-Tags"
+
+// DefaultTagHeaders contains the names of headers commonly used by origins to assign tags to responses.
+var DefaultTagHeaders = []string{"Surrogate-Key", "Cache-Tag", "Xkey"}
+
+// ParseTags parses the values of a header containing tags and returns the tags in the order encountered, without
+// duplicates.
+//
+// Tags can be separated by whitespace or commas, which allows parsing the Surrogate-Key, Cache-Tag and xkey headers.
+func ParseTags(lines []string) []string {
+	var tags []string
+
+	for _, line := range lines {
+		for tag := range strings.FieldsFuncSeq(line, isTagSeparator) {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	return tags
+}
+
+func isTagSeparator(r rune) bool {
+	return r == ' ' || r == '\t' || r == ','
+}
+
+// PurgeMode is an enumeration of the ways responses can be purged from a [Store].
+type PurgeMode uint8
+
+const (
+	// PurgeHard causes matching responses to be deleted.
+	PurgeHard PurgeMode = iota
+
+	// PurgeSoft causes matching responses to be marked as stale, so that they are validated before being used again.
+	//
+	// Stale responses may still be used if allowed, e.g. using the max-stale request directive.
+	PurgeSoft
+)
+
+// String implements the [fmt.Stringer] interface.
+func (m PurgeMode) String() string {
+	switch m {
+	case PurgeHard:
+		return "hard-purge"
+	case PurgeSoft:
+		return "soft-purge"
+	}
+
+	panic("invalid PurgeMode")
+}
+
+// TagPurger is an optional interface that can be implemented by a [Store] to support purging responses by tag.
+//
+// The tags of a response are passed to the store using the [TagsHeader] header.
+type TagPurger interface {
+	// PurgeTags purges all stored responses with at least one of the given tags and returns the number of purged
+	// responses.
+	PurgeTags(ctx context.Context, mode PurgeMode, tags ...string) (int, error)
+}
+
+var errPurgeTagsUnsupported = fmt.Errorf("store does not implement TagPurger: %w", errors.ErrUnsupported)
+
+// PurgeTags purges all stored responses with at least one of the given tags and returns the number of purged
+// responses.
+//
+// If the [Store] does not implement [TagPurger], an error matching [errors.ErrUnsupported] is returned.
+func (c *Client) PurgeTags(ctx context.Context, mode PurgeMode, tags ...string) (int, error) {
+	purger, ok := c.Store.(TagPurger)
+	if !ok {
+		return 0, errPurgeTagsUnsupported
+	}
+
+	n, err := purger.PurgeTags(ctx, mode, tags...)
+
+	if n > 0 || err != nil {
+		c.observe(ctx, nopSpan{}, Event{Type: EventInvalidated, Reason: mode.String(), Err: err})
+	}
+
+	return n, err
+}
+
+// collectTags replaces the headers listed in [Client.TagHeaders] with a single [TagsHeader] header.
+//
+// If none of the headers is set, the header is not modified.
+func (c *Client) collectTags(h http.Header) {
+	var lines []string
+	for _, name := range c.TagHeaders {
+		name = http.CanonicalHeaderKey(name)
+		lines = append(lines, h[name]...)
+		delete(h, name)
+	}
+
+	if lines == nil {
+		return
+	}
+
+	if tags := ParseTags(lines); len(tags) > 0 {
+		h.Set(TagsHeader, strings.Join(tags, " "))
+	} else {
+		h.Del(TagsHeader)
+	}
+}
+
+// removeTags removes the headers listed in [Client.TagHeaders] as well as the [TagsHeader] header.
+func (c *Client) removeTags(h http.Header) {
+	for _, name := range c.TagHeaders {
+		h.Del(name)
+	}
+	h.Del(TagsHeader)
+}
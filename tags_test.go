@@ -0,0 +1,174 @@
+package httpcache_test
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nussjustin/httpcache"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "space separated",
+			in:   []string{"product-1  product-2\tcategory-3"},
+			want: []string{"product-1", "product-2", "category-3"},
+		},
+		{
+			name: "comma separated",
+			in:   []string{"product-1,product-2, category-3"},
+			want: []string{"product-1", "product-2", "category-3"},
+		},
+		{
+			name: "multiple lines with duplicates",
+			in:   []string{"product-1 product-2", "product-2", "", "product-1 category-3"},
+			want: []string{"product-1", "product-2", "category-3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := httpcache.ParseTags(tt.in); !slices.Equal(got, tt.want) {
+				t.Errorf("ParseTags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPurgeMode_String(t *testing.T) {
+	for m := httpcache.PurgeHard; m <= httpcache.PurgeSoft; m++ {
+		if s := m.String(); s == "" || strings.ContainsAny(s, " \t\",;=") {
+			t.Errorf("PurgeMode(%d).String() = %q, want non-empty token", m, s)
+		}
+	}
+}
+
+func TestClient_PurgeTags(t *testing.T) {
+	clock := httpcache.NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var requests []*http.Request
+
+	observer := &recordingObserver{}
+
+	client := &httpcache.Client{
+		Config:     httpcache.Config{Clock: clock},
+		Store:      &httpcache.MemoryStore{Clock: clock},
+		Observer:   observer,
+		TagHeaders: httpcache.DefaultTagHeaders,
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				requests = append(requests, req)
+
+				resp := newResp(
+					withRespHeader("Cache-Control", "max-age=60"),
+					withRespHeader("Etag", `"tag"`))
+
+				switch req.URL.Path {
+				case "/product-1":
+					resp.Header.Set("Surrogate-Key", "product-1 products")
+				case "/product-2":
+					resp.Header.Set("Cache-Tag", "product-2,products")
+				case "/other":
+					resp.Header.Set("Xkey", "other")
+				}
+
+				if req.Header.Get("If-None-Match") != "" {
+					resp = newResp(withRespStatus(http.StatusNotModified))
+				}
+
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	do := func(path string) *http.Response {
+		t.Helper()
+
+		resp, err := client.Do(newReq(withReqUrl("http://example.com" + path)))
+		if err != nil {
+			t.Fatalf("Do(%s) error = %v", path, err)
+		}
+
+		for _, name := range []string{"Surrogate-Key", "Cache-Tag", "Xkey", httpcache.TagsHeader} {
+			if v := resp.Header.Get(name); v != "" {
+				t.Errorf("Do(%s) Response.Header[%s] = %q, want empty", path, name, v)
+			}
+		}
+
+		return resp
+	}
+
+	for _, path := range []string{"/product-1", "/product-2", "/other", "/product-1", "/product-2", "/other"} {
+		_ = do(path)
+	}
+
+	if got, want := len(requests), 3; got != want {
+		t.Fatalf("got %d requests, want %d", got, want)
+	}
+
+	n, err := client.PurgeTags(t.Context(), httpcache.PurgeSoft, "product-1")
+	if err != nil {
+		t.Fatalf("PurgeTags() error = %v", err)
+	}
+
+	if got, want := n, 1; got != want {
+		t.Errorf("PurgeTags() = %d, want %d", got, want)
+	}
+
+	_ = do("/product-1")
+
+	if got, want := len(requests), 4; got != want {
+		t.Fatalf("got %d requests after soft purge, want %d", got, want)
+	}
+
+	if got, want := requests[3].Header.Get("If-None-Match"), `"tag"`; got != want {
+		t.Errorf("got If-None-Match = %q after soft purge, want %q", got, want)
+	}
+
+	_ = observer.types()
+
+	// The revalidated response must keep its tags
+	n, err = client.PurgeTags(t.Context(), httpcache.PurgeHard, "products")
+	if err != nil {
+		t.Fatalf("PurgeTags() error = %v", err)
+	}
+
+	if got, want := n, 2; got != want {
+		t.Errorf("PurgeTags() = %d, want %d", got, want)
+	}
+
+	if got, want := observer.types(), []httpcache.EventType{httpcache.EventInvalidated}; !slices.Equal(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+
+	_ = do("/product-1")
+	_ = do("/product-2")
+	_ = do("/other")
+
+	if got, want := len(requests), 6; got != want {
+		t.Fatalf("got %d requests after hard purge, want %d", got, want)
+	}
+
+	if requests[4].Header.Get("If-None-Match") != "" || requests[5].Header.Get("If-None-Match") != "" {
+		t.Errorf("got conditional request after hard purge")
+	}
+}
+
+func TestClient_PurgeTags_Unsupported(t *testing.T) {
+	client := &httpcache.Client{Store: &trackingStore{Store: httpcache.NewMemoryStore()}}
+
+	if _, err := client.PurgeTags(t.Context(), httpcache.PurgeHard, "tag"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("PurgeTags() error = %v, want %v", err, errors.ErrUnsupported)
+	}
+}
@@ -0,0 +1,329 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// TieredWritePolicy is an enumeration of policies for writing responses to a [TieredStore].
+type TieredWritePolicy uint8
+
+const (
+	// TieredWriteBoth causes responses to be written to both the L1 and the L2 store.
+	TieredWriteBoth TieredWritePolicy = iota
+
+	// TieredWriteL2Only causes responses to be written only to the L2 store. Responses are added to the L1 store when
+	// they are read from the L2 store.
+	//
+	// A response for the same request that was previously added to the L1 store is removed, or replaced if the L1
+	// store does not implement [StoreDeleter].
+	TieredWriteL2Only
+)
+
+// String implements the [fmt.Stringer] interface.
+func (p TieredWritePolicy) String() string {
+	switch p {
+	case TieredWriteBoth:
+		return "both"
+	case TieredWriteL2Only:
+		return "l2-only"
+	}
+
+	panic("invalid TieredWritePolicy")
+}
+
+// TieredStore is a [Store] that combines a small and fast L1 store, for example a [MemoryStore], with a larger but
+// slower L2 store, for example a store backed by a disk or a remote service.
+//
+// Responses are looked up in the L1 store first and then in the L2 store. Responses found in the L2 store are added to
+// the L1 store, so that following requests can be served from the L1 store.
+//
+// Errors returned by the L2 store are passed to L2ErrorHandler, if set, but are otherwise ignored, so that the store
+// continues to work using only the L1 store. Errors returned by the L1 store are returned as is, except for errors
+// when adding responses from the L2 store, which are passed to L1ErrorHandler, if set.
+//
+// TieredStore implements [StoreLister] and [StoreStatsReporter] by forwarding to the stores that implement the
+// interface. It implements [StoreDeleter], [TagPurger], [PartitionPurger] and [URLPurger] by forwarding to both
+// stores, which must both implement the interface, since otherwise removed responses could still be returned from the
+// other store.
+// If a required interface is not implemented, the methods return an error matching [errors.ErrUnsupported]. Errors
+// returned by these methods are returned from both stores.
+type TieredStore struct {
+	// L1 is the first level store.
+	L1 Store
+
+	// L2 is the second level store.
+	L2 Store
+
+	// Clock is used to determine the request and response times passed to the L1 store when adding responses from the
+	// L2 store.
+	//
+	// If nil, [time.Now] is used.
+	Clock Clock
+
+	// L1ErrorHandler, if set, is called with the errors returned by the L1 store when adding a response from the L2
+	// store. The response from the L2 store is returned regardless.
+	L1ErrorHandler func(ctx context.Context, err error)
+
+	// L2ErrorHandler, if set, is called with all errors returned by the L2 store.
+	L2ErrorHandler func(ctx context.Context, err error)
+
+	// WritePolicy determines to which stores responses are written.
+	WritePolicy TieredWritePolicy
+}
+
+// Get implements the [Store] interface.
+func (t *TieredStore) Get(ctx context.Context, req *http.Request) (*http.Response, error) {
+	resp, err := t.L1.Get(ctx, req)
+	if err != nil || resp != nil {
+		return resp, err
+	}
+
+	resp, err = t.L2.Get(ctx, req)
+	if err != nil {
+		t.l2Error(ctx, fmt.Errorf("get from L2 store: %w", err))
+		return nil, nil
+	}
+
+	if resp == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.l2Error(ctx, fmt.Errorf("read body from L2 store: %w", err))
+		return nil, nil
+	}
+
+	// The response returned by the L2 store contains its current age in the Age header. Storing it with the current
+	// time as request and response time causes the L1 store to continue from this age.
+	now := nowFrom(t.Clock)
+
+	promoted := *resp
+	promoted.Header = cloneHeader(resp.Header)
+	promoted.Trailer = cloneHeader(resp.Trailer)
+	promoted.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := t.L1.Set(ctx, req, now, &promoted, now); err != nil && t.L1ErrorHandler != nil {
+		t.L1ErrorHandler(ctx, fmt.Errorf("add response from L2 store to L1 store: %w", err))
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+
+	return resp, nil
+}
+
+// Set implements the [Store] interface.
+func (t *TieredStore) Set(
+	ctx context.Context,
+	req *http.Request, reqTime time.Time,
+	resp *http.Response, respTime time.Time,
+) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	withBody := func() *http.Response {
+		c := *resp
+		c.Header = cloneHeader(resp.Header)
+		c.Trailer = cloneHeader(resp.Trailer)
+		c.Body = io.NopCloser(bytes.NewReader(body))
+		return &c
+	}
+
+	l2Err := t.L2.Set(ctx, req, reqTime, withBody(), respTime)
+	if l2Err != nil {
+		t.l2Error(ctx, fmt.Errorf("set in L2 store: %w", l2Err))
+	}
+
+	// If the L2 store failed, the response is stored in the L1 store regardless of the policy.
+	if t.WritePolicy == TieredWriteL2Only && l2Err == nil {
+		return t.removeFromL1(ctx, req, reqTime, withBody(), respTime)
+	}
+
+	return t.L1.Set(ctx, req, reqTime, withBody(), respTime)
+}
+
+// removeFromL1 makes sure that a response previously promoted to the L1 store is not used after a new response was
+// written only to the L2 store.
+//
+// If the L1 store implements [StoreDeleter], the old response is deleted. Otherwise, it is replaced with the new
+// response.
+func (t *TieredStore) removeFromL1(
+	ctx context.Context,
+	req *http.Request, reqTime time.Time,
+	resp *http.Response, respTime time.Time,
+) error {
+	if deleter, ok := t.L1.(StoreDeleter); ok {
+		_, err := deleter.DeleteRequest(ctx, req)
+		return err
+	}
+
+	old, err := t.L1.Get(ctx, req)
+	if err != nil || old == nil {
+		return err
+	}
+
+	_ = old.Body.Close()
+
+	return t.L1.Set(ctx, req, reqTime, resp, respTime)
+}
+
+func (t *TieredStore) l2Error(ctx context.Context, err error) {
+	if t.L2ErrorHandler != nil {
+		t.L2ErrorHandler(ctx, err)
+	}
+}
+
+var errListUnsupported = fmt.Errorf("store does not implement StoreLister: %w", errors.ErrUnsupported)
+
+// tiers returns the L1 and L2 store as T, skipping stores that do not implement T.
+func tiers[T any](t *TieredStore) []T {
+	var s []T
+
+	for _, store := range []Store{t.L1, t.L2} {
+		if v, ok := store.(T); ok {
+			s = append(s, v)
+		}
+	}
+
+	return s
+}
+
+// removeFromTiers calls f for both stores and returns the highest number of removed responses, so that responses
+// removed from both stores are only counted once.
+//
+// If one of the stores does not implement T, nothing is removed and unsupported is returned, wrapped to name the store
+// if only one store is affected.
+func removeFromTiers[T any](t *TieredStore, unsupported error, f func(T) (int, error)) (int, error) {
+	l1, l1Ok := t.L1.(T)
+	l2, l2Ok := t.L2.(T)
+
+	switch {
+	case !l1Ok && !l2Ok:
+		return 0, unsupported
+	case !l1Ok:
+		return 0, fmt.Errorf("L1 %w", unsupported)
+	case !l2Ok:
+		return 0, fmt.Errorf("L2 %w", unsupported)
+	}
+
+	n1, err1 := f(l1)
+	n2, err2 := f(l2)
+
+	return max(n1, n2), errors.Join(err1, err2)
+}
+
+// Entries implements the [StoreLister] interface.
+//
+// The entries of the L2 store are returned first, followed by the entries of the L1 store that were not already
+// returned for the L2 store. Entries are considered the same if they have the same partition, method, URL and variant.
+func (t *TieredStore) Entries(ctx context.Context) iter.Seq2[StoreEntry, error] {
+	return func(yield func(StoreEntry, error) bool) {
+		listers := tiers[StoreLister](t)
+		if len(listers) == 0 {
+			yield(StoreEntry{}, errListUnsupported)
+			return
+		}
+
+		type identity struct {
+			partition, method, url, variant string
+		}
+
+		seen := make(map[identity]struct{})
+
+		// Iterate from L2 to L1, since the L2 store usually contains all responses.
+		slices.Reverse(listers)
+
+		for i, lister := range listers {
+			for e, err := range lister.Entries(ctx) {
+				if err != nil {
+					if !yield(StoreEntry{}, err) {
+						return
+					}
+					continue
+				}
+
+				id := identity{e.Partition, e.Method, e.URL, e.Variant}
+
+				if _, ok := seen[id]; ok {
+					continue
+				}
+
+				// Only entries from the first store need to be remembered.
+				if i == 0 && len(listers) > 1 {
+					seen[id] = struct{}{}
+				}
+
+				if !yield(e, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Delete implements the [StoreDeleter] interface.
+//
+// Keys are specific to the store that returned them, but are passed to both stores.
+func (t *TieredStore) Delete(ctx context.Context, key string) (int, error) {
+	return removeFromTiers(t, errDeleteUnsupported, func(d StoreDeleter) (int, error) {
+		return d.Delete(ctx, key)
+	})
+}
+
+// DeleteRequest implements the [StoreDeleter] interface.
+func (t *TieredStore) DeleteRequest(ctx context.Context, req *http.Request) (int, error) {
+	return removeFromTiers(t, errDeleteUnsupported, func(d StoreDeleter) (int, error) {
+		return d.DeleteRequest(ctx, req)
+	})
+}
+
+// Stats implements the [StoreStatsReporter] interface.
+//
+// If at least one store implements [StoreLister], the statistics are calculated from the entries returned by
+// [TieredStore.Entries]. Otherwise, the statistics of the L2 store are used, or those of the L1 store if the L2 store
+// does not implement [StoreStatsReporter].
+func (t *TieredStore) Stats(ctx context.Context) (StoreStats, error) {
+	if len(tiers[StoreLister](t)) > 0 {
+		return statsFromEntries(t.Entries(ctx))
+	}
+
+	reporters := tiers[StoreStatsReporter](t)
+	if len(reporters) == 0 {
+		return StoreStats{}, errStatsUnsupported
+	}
+
+	return reporters[len(reporters)-1].Stats(ctx)
+}
+
+// PurgeTags implements the [TagPurger] interface.
+func (t *TieredStore) PurgeTags(ctx context.Context, mode PurgeMode, tags ...string) (int, error) {
+	return removeFromTiers(t, errPurgeTagsUnsupported, func(p TagPurger) (int, error) {
+		return p.PurgeTags(ctx, mode, tags...)
+	})
+}
+
+// PurgeURL implements the [URLPurger] interface.
+func (t *TieredStore) PurgeURL(ctx context.Context, method, url string) (int, error) {
+	return removeFromTiers(t, errPurgeURLUnsupported, func(p URLPurger) (int, error) {
+		return p.PurgeURL(ctx, method, url)
+	})
+}
+
+// PurgePartition implements the [PartitionPurger] interface.
+func (t *TieredStore) PurgePartition(ctx context.Context, partition string) (int, error) {
+	return removeFromTiers(t, errPurgePartitionUnsupported, func(p PartitionPurger) (int, error) {
+		return p.PurgePartition(ctx, partition)
+	})
+}
@@ -0,0 +1,350 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nussjustin/httpcache"
+	"github.com/nussjustin/httpcache/storetest"
+)
+
+func TestTieredStore(t *testing.T) {
+	for _, policy := range []httpcache.TieredWritePolicy{httpcache.TieredWriteBoth, httpcache.TieredWriteL2Only} {
+		t.Run(policy.String(), func(t *testing.T) {
+			storetest.Run(t, func() httpcache.Store {
+				return &httpcache.TieredStore{
+					L1:          httpcache.NewMemoryStore(),
+					L2:          httpcache.NewMemoryStore(),
+					WritePolicy: policy,
+				}
+			})
+		})
+	}
+}
+
+func TestTieredStore_WritePolicy(t *testing.T) {
+	tests := []struct {
+		policy   httpcache.TieredWritePolicy
+		wantL1   int
+		wantL2   int
+		failL2   bool
+		wantErrs int
+	}{
+		{policy: httpcache.TieredWriteBoth, wantL1: 1, wantL2: 1},
+		{policy: httpcache.TieredWriteL2Only, wantL1: 0, wantL2: 1},
+		{policy: httpcache.TieredWriteBoth, wantL1: 1, failL2: true, wantErrs: 1},
+		{policy: httpcache.TieredWriteL2Only, wantL1: 1, failL2: true, wantErrs: 1},
+	}
+	for _, tt := range tests {
+		l1 := &trackingStore{Store: httpcache.NewMemoryStore()}
+		l2 := &trackingStore{Store: httpcache.NewMemoryStore(), failOnStore: tt.failL2}
+
+		var errs int
+
+		store := &httpcache.TieredStore{
+			L1: l1,
+			L2: l2,
+			L2ErrorHandler: func(_ context.Context, err error) {
+				if !errors.Is(err, errStoreFail) {
+					t.Errorf("got error %v, want %v", err, errStoreFail)
+				}
+				errs++
+			},
+			WritePolicy: tt.policy,
+		}
+
+		if err := store.Set(t.Context(), newReq(), time.Now(), newResp(), time.Now()); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+
+		if l1.stored != tt.wantL1 || l2.stored != tt.wantL2 || errs != tt.wantErrs {
+			t.Errorf("%s (L2 failing: %v): got %d L1 writes, %d L2 writes and %d errors, want %d, %d and %d",
+				tt.policy, tt.failL2, l1.stored, l2.stored, errs, tt.wantL1, tt.wantL2, tt.wantErrs)
+		}
+	}
+}
+
+func TestTieredStore_Promotion(t *testing.T) {
+	clock := httpcache.NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	l1 := &trackingStore{Store: &httpcache.MemoryStore{Clock: clock}}
+	l2 := &trackingStore{Store: &httpcache.MemoryStore{Clock: clock}}
+
+	store := &httpcache.TieredStore{
+		L1:          l1,
+		L2:          l2,
+		Clock:       clock,
+		WritePolicy: httpcache.TieredWriteL2Only,
+	}
+
+	if err := store.Set(t.Context(), newReq(), clock.Now(), newResp(withRespHeader("Age", "10")), clock.Now()); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	get := func(wantAge string) {
+		t.Helper()
+
+		resp, err := store.Get(t.Context(), newReq())
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if resp == nil {
+			t.Fatal("Get() returned no response")
+		}
+		_ = resp.Body.Close()
+
+		if got := resp.Header.Get("Age"); got != wantAge {
+			t.Errorf("got Age %q, want %q", got, wantAge)
+		}
+	}
+
+	clock.Advance(20 * time.Second)
+	get("30")
+
+	if got, want := l1.stored, 1; got != want {
+		t.Errorf("got %d L1 writes after first Get, want %d", got, want)
+	}
+
+	l2.failOnGet = true
+
+	clock.Advance(15 * time.Second)
+	get("45")
+
+	if got, want := l1.stored, 1; got != want {
+		t.Errorf("got %d L1 writes after second Get, want %d", got, want)
+	}
+}
+
+func TestTieredStore_L2Only_Update(t *testing.T) {
+	l1s := map[string]func(httpcache.Clock) httpcache.Store{
+		"deleter": func(clock httpcache.Clock) httpcache.Store {
+			return &httpcache.MemoryStore{Clock: clock}
+		},
+		"non-deleter": func(clock httpcache.Clock) httpcache.Store {
+			return &trackingStore{Store: &httpcache.MemoryStore{Clock: clock}}
+		},
+	}
+
+	for name, newL1 := range l1s {
+		t.Run(name, func(t *testing.T) {
+			clock := httpcache.NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+			var requests int
+
+			client := &httpcache.Client{
+				Config: httpcache.Config{Clock: clock},
+				Store: &httpcache.TieredStore{
+					L1:          newL1(clock),
+					L2:          &httpcache.MemoryStore{Clock: clock},
+					Clock:       clock,
+					WritePolicy: httpcache.TieredWriteL2Only,
+				},
+				HTTPClient: &http.Client{
+					Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+						requests++
+
+						status := http.StatusOK
+						if req.Header.Get("If-None-Match") != "" {
+							status = http.StatusNotModified
+						}
+
+						resp := newResp(
+							withRespStatus(status),
+							withRespHeader("Cache-Control", "max-age=60"),
+							withRespHeader("Date", clock.Now().Format(http.TimeFormat)),
+							withRespHeader("Etag", `"1"`))
+						resp.Request = req
+						return resp, nil
+					}),
+				},
+			}
+
+			do := func() {
+				t.Helper()
+
+				if _, err := client.Do(newReq()); err != nil {
+					t.Fatalf("Do() error = %v", err)
+				}
+			}
+
+			// Stored in L2, then promoted to L1.
+			do()
+			do()
+
+			clock.Advance(2 * time.Minute)
+
+			// The stale response in L1 is revalidated and the updated response is only written to L2.
+			do()
+			do()
+			do()
+
+			if got, want := requests, 2; got != want {
+				t.Errorf("got %d requests, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestTieredStore_OptionalInterfaces(t *testing.T) {
+	l1 := httpcache.NewMemoryStore()
+	l2 := httpcache.NewMemoryStore()
+
+	client := &httpcache.Client{
+		Store: &httpcache.TieredStore{L1: l1, L2: l2},
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				resp := newResp(withRespHeader("Cache-Control", "max-age=60"))
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+
+	for _, u := range []string{"http://example.com/a", "http://example.com/b"} {
+		if _, err := client.Do(newReq(withReqUrl(u))); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+	}
+
+	// Responses stored in both stores must only be counted once.
+	stats, err := client.Stats(t.Context())
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+
+	if got, want := stats.Entries, 2; got != want {
+		t.Errorf("got %d entries, want %d", got, want)
+	}
+
+	n, err := client.Delete(t.Context(), newReq(withReqUrl("http://example.com/a")))
+	if err != nil || n != 1 {
+		t.Errorf("Delete() = %d, %v, want 1, nil", n, err)
+	}
+
+	for name, store := range map[string]httpcache.Store{"L1": l1, "L2": l2} {
+		resp, err := store.Get(t.Context(), newReq(withReqUrl("http://example.com/a")))
+		if err != nil || resp != nil {
+			t.Errorf("%s Get() after Delete() = %v, %v, want nil, nil", name, resp, err)
+		}
+	}
+}
+
+func TestTieredStore_OptionalInterfaces_Unsupported(t *testing.T) {
+	store := &httpcache.TieredStore{
+		L1: &trackingStore{Store: httpcache.NewMemoryStore()},
+		L2: &trackingStore{Store: httpcache.NewMemoryStore()},
+	}
+
+	if _, err := store.DeleteRequest(t.Context(), newReq()); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("DeleteRequest() error = %v, want %v", err, errors.ErrUnsupported)
+	}
+
+	if _, err := store.Stats(t.Context()); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Stats() error = %v, want %v", err, errors.ErrUnsupported)
+	}
+
+	if _, err := store.PurgeTags(t.Context(), httpcache.PurgeHard, "a"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("PurgeTags() error = %v, want %v", err, errors.ErrUnsupported)
+	}
+
+	if _, err := store.PurgePartition(t.Context(), "a"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("PurgePartition() error = %v, want %v", err, errors.ErrUnsupported)
+	}
+
+	for _, err := range store.Entries(t.Context()) {
+		if !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("Entries() error = %v, want %v", err, errors.ErrUnsupported)
+		}
+	}
+}
+
+func TestTieredStore_OptionalInterfaces_OneTier(t *testing.T) {
+	for name, store := range map[string]*httpcache.TieredStore{
+		"L1": {L1: httpcache.NewMemoryStore(), L2: &trackingStore{Store: httpcache.NewMemoryStore()}},
+		"L2": {L1: &trackingStore{Store: httpcache.NewMemoryStore()}, L2: httpcache.NewMemoryStore()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := store.Set(t.Context(), newReq(), time.Now(), newResp(), time.Now()); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+
+			if _, err := store.DeleteRequest(t.Context(), newReq()); !errors.Is(err, errors.ErrUnsupported) {
+				t.Errorf("DeleteRequest() error = %v, want %v", err, errors.ErrUnsupported)
+			}
+
+			if _, err := store.PurgeTags(t.Context(), httpcache.PurgeHard, "a"); !errors.Is(err, errors.ErrUnsupported) {
+				t.Errorf("PurgeTags() error = %v, want %v", err, errors.ErrUnsupported)
+			}
+
+			if _, err := store.PurgePartition(t.Context(), "a"); !errors.Is(err, errors.ErrUnsupported) {
+				t.Errorf("PurgePartition() error = %v, want %v", err, errors.ErrUnsupported)
+			}
+
+			// Nothing must be removed, so that both stores stay consistent.
+			for tier, s := range map[string]httpcache.Store{"L1": store.L1, "L2": store.L2} {
+				if resp, err := s.Get(t.Context(), newReq()); err != nil || resp == nil {
+					t.Errorf("%s Get() = %v, %v, want response", tier, resp, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTieredStore_PromotionError(t *testing.T) {
+	l2 := httpcache.NewMemoryStore()
+
+	if err := l2.Set(t.Context(), newReq(), time.Now(), newResp(), time.Now()); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	var errs []error
+
+	store := &httpcache.TieredStore{
+		L1: &trackingStore{Store: httpcache.NewMemoryStore(), failOnStore: true},
+		L2: l2,
+		L1ErrorHandler: func(_ context.Context, err error) {
+			errs = append(errs, err)
+		},
+	}
+
+	resp, err := store.Get(t.Context(), newReq())
+	if err != nil || resp == nil {
+		t.Fatalf("Get() = %v, %v, want response", resp, err)
+	}
+
+	if len(errs) != 1 || !errors.Is(errs[0], errStoreFail) {
+		t.Errorf("got errors %v, want %v", errs, errStoreFail)
+	}
+}
+
+func TestTieredStore_L2Error(t *testing.T) {
+	var errs int
+
+	store := &httpcache.TieredStore{
+		L1: httpcache.NewMemoryStore(),
+		L2: &trackingStore{Store: httpcache.NewMemoryStore(), failOnGet: true},
+		L2ErrorHandler: func(context.Context, error) {
+			errs++
+		},
+	}
+
+	resp, err := store.Get(t.Context(), newReq())
+	if err != nil || resp != nil {
+		t.Errorf("Get() = %v, %v, want nil, nil", resp, err)
+	}
+
+	if got, want := errs, 1; got != want {
+		t.Errorf("got %d errors, want %d", got, want)
+	}
+}
+
+func TestTieredWritePolicy_String(t *testing.T) {
+	for p := httpcache.TieredWriteBoth; p <= httpcache.TieredWriteL2Only; p++ {
+		if s := p.String(); s == "" {
+			t.Errorf("TieredWritePolicy(%d).String() = %q, want non-empty string", p, s)
+		}
+	}
+}
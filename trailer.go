@@ -0,0 +1,46 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+)
+
+// deferTrailer changes the given response so that the values of its trailers only become visible after the body was
+// read until [io.EOF], the same as for responses read by [http.Transport].
+//
+// Until then, the Trailer map only contains the names of the trailers with nil values.
+func deferTrailer(resp *http.Response) {
+	if len(resp.Trailer) == 0 || resp.Body == nil {
+		return
+	}
+
+	trailer := resp.Trailer
+
+	resp.Trailer = make(http.Header, len(trailer))
+	for name := range trailer {
+		resp.Trailer[name] = nil
+	}
+
+	wrapBody(resp, func(body io.ReadCloser) io.ReadCloser {
+		return &trailerBody{ReadCloser: body, dst: resp.Trailer, src: trailer}
+	})
+}
+
+// trailerBody copies trailers into the Trailer map of a response once the body was read until [io.EOF].
+type trailerBody struct {
+	io.ReadCloser
+	dst, src http.Header
+}
+
+func (b *trailerBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	if err == io.EOF && b.src != nil {
+		for name, values := range b.src {
+			b.dst[name] = values
+		}
+		b.src = nil
+	}
+
+	return n, err
+}
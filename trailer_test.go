@@ -0,0 +1,83 @@
+package httpcache_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nussjustin/httpcache"
+)
+
+func TestClient_Do_Trailer(t *testing.T) {
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Trailer", "X-Checksum, X-Secret")
+
+		_, _ = io.WriteString(w, "hello")
+
+		w.Header().Set("X-Checksum", "abc")
+		w.Header().Set("X-Secret", "token")
+	}))
+	t.Cleanup(server.Close)
+
+	client := &httpcache.Client{
+		Config:     httpcache.Config{SensitiveHeaders: []string{"X-Secret"}},
+		Store:      httpcache.NewMemoryStore(),
+		HTTPClient: server.Client(),
+	}
+
+	do := func() *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatalf("NewRequest() error = %v", err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+
+		if got := resp.Trailer.Get("X-Checksum"); got != "" {
+			t.Errorf("got trailer X-Checksum %q before reading the body, want none", got)
+		}
+
+		if _, ok := resp.Trailer["X-Checksum"]; !ok {
+			t.Errorf("trailer X-Checksum not announced before reading the body")
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+		_ = resp.Body.Close()
+
+		if got, want := string(body), "hello"; got != want {
+			t.Errorf("got body %q, want %q", got, want)
+		}
+
+		if got, want := resp.Trailer.Get("X-Checksum"), "abc"; got != want {
+			t.Errorf("got trailer X-Checksum %q after reading the body, want %q", got, want)
+		}
+
+		return resp
+	}
+
+	if got, want := do().Trailer.Get("X-Secret"), "token"; got != want {
+		t.Errorf("got trailer X-Secret %q on fetched response, want %q", got, want)
+	}
+
+	if got := do().Trailer.Get("X-Secret"); got != "" {
+		t.Errorf("got trailer X-Secret %q on cached response, want none", got)
+	}
+
+	if got, want := requests, 1; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+}
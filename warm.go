@@ -0,0 +1,300 @@
+package httpcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WarmOptions configures [Client.Warm].
+//
+// The zero value warms one request at a time without rate limit.
+type WarmOptions struct {
+	// Concurrency is the maximum number of requests sent at the same time.
+	//
+	// If zero or negative, requests are sent one at a time.
+	Concurrency int
+
+	// Interval is the minimum time between sending two requests to the origin.
+	//
+	// Requests for responses that are already fresh are not sent and are not affected by the interval. If zero, the
+	// rate of requests is only limited by Concurrency.
+	Interval time.Duration
+
+	// Variants contains sets of request headers used to warm different variants of each response, for example one set
+	// for each value of Accept-Language listed in the Vary header of the responses.
+	//
+	// If set, each request is warmed once for each variant, with the headers of the variant replacing the headers of
+	// the same name in the request. Otherwise, each request is warmed once as is.
+	Variants []http.Header
+}
+
+// WarmOutcome is an enumeration of the possible outcomes of warming a single request using [Client.Warm].
+type WarmOutcome uint8
+
+const (
+	// WarmFresh is used when a fresh response was already stored and no request was sent.
+	WarmFresh WarmOutcome = iota
+
+	// WarmStored is used when a new response was received from the origin and stored.
+	WarmStored
+
+	// WarmRevalidated is used when a stored response was validated by the origin and updated.
+	WarmRevalidated
+
+	// WarmNotStored is used when a response was received from the origin, but could not be stored.
+	WarmNotStored
+
+	// WarmFailed is used when the request failed or was canceled.
+	WarmFailed
+)
+
+// String implements the [fmt.Stringer] interface.
+func (o WarmOutcome) String() string {
+	switch o {
+	case WarmFresh:
+		return "fresh"
+	case WarmStored:
+		return "stored"
+	case WarmRevalidated:
+		return "revalidated"
+	case WarmNotStored:
+		return "not-stored"
+	case WarmFailed:
+		return "failed"
+	}
+
+	panic("invalid WarmOutcome")
+}
+
+// WarmResult is the result of warming a single request using [Client.Warm].
+type WarmResult struct {
+	// Request is the warmed request, including the headers of the variant, if any.
+	Request *http.Request
+
+	// Outcome is the outcome of warming the request.
+	Outcome WarmOutcome
+
+	// StatusCode is the status code of the returned response or zero if the request failed.
+	StatusCode int
+
+	// Err is the error returned for the request, if any.
+	Err error
+}
+
+// Warm populates the cache by sending the given requests and storing the responses, for example after a deploy.
+//
+// Requests for which a fresh response is already stored are skipped. Requests that bypass the cache, for example
+// because their method is not supported, are sent once and reported as [WarmNotStored].
+//
+// Each request is warmed once for each variant in [WarmOptions.Variants], and the returned results are in the same
+// order as the given requests and variants.
+//
+// The context of the given requests is replaced with ctx. If ctx is canceled, Warm stops sending requests and returns
+// the results for all remaining requests as [WarmFailed].
+func (c *Client) Warm(ctx context.Context, reqs []*http.Request, opts WarmOptions) []WarmResult {
+	variants := opts.Variants
+	if len(variants) == 0 {
+		variants = []http.Header{nil}
+	}
+
+	results := make([]WarmResult, 0, len(reqs)*len(variants))
+
+	for _, req := range reqs {
+		for _, variant := range variants {
+			req := req.Clone(ctx)
+
+			for name, values := range variant {
+				req.Header[http.CanonicalHeaderKey(name)] = slices.Clone(values)
+			}
+
+			results = append(results, WarmResult{Request: req})
+		}
+	}
+
+	limiter := &warmLimiter{interval: opts.Interval}
+
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for range max(opts.Concurrency, 1) {
+		wg.Go(func() {
+			for i := range indexes {
+				c.warm(ctx, limiter, &results[i])
+			}
+		})
+	}
+
+	for i := range results {
+		indexes <- i
+	}
+
+	close(indexes)
+
+	wg.Wait()
+
+	return results
+}
+
+// WarmURLs is like [Client.Warm], but sends GET requests for the URLs read from r.
+//
+// The input must contain one absolute URL per line. Empty lines and lines starting with "#" are ignored. If any line
+// can not be parsed, no requests are sent and an error is returned.
+func (c *Client) WarmURLs(ctx context.Context, r io.Reader, opts WarmOptions) ([]WarmResult, error) {
+	var reqs []*http.Request
+
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s, nil)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return c.Warm(ctx, reqs, opts), nil
+}
+
+// warm warms the request of the given result and fills in the rest of the result.
+func (c *Client) warm(ctx context.Context, limiter *warmLimiter, result *WarmResult) {
+	req := result.Request
+
+	if err := ctx.Err(); err != nil {
+		result.Outcome, result.Err = WarmFailed, err
+		return
+	}
+
+	// Requests that bypass the cache are never served from the store, so they are only sent once and reported as not
+	// stored.
+	config := c.config()
+
+	if _, bypass := c.bypassReason(ctx, config, req); !bypass {
+		if status, ok := c.probeFresh(ctx, config, req); ok {
+			result.Outcome, result.StatusCode = WarmFresh, status
+			return
+		}
+	}
+
+	if err := limiter.wait(ctx); err != nil {
+		result.Outcome, result.Err = WarmFailed, err
+		return
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		result.Outcome, result.Err = WarmFailed, err
+		return
+	}
+
+	discardBody(resp)
+
+	result.StatusCode = resp.StatusCode
+
+	switch info, _ := Info(resp); {
+	case info.Revalidation == RevalidationNotModified:
+		result.Outcome = WarmRevalidated
+	case !info.StoredAt.IsZero():
+		result.Outcome = WarmStored
+	default:
+		result.Outcome = WarmNotStored
+	}
+}
+
+// probeFresh looks up the stored response for the given request and returns its status code if it is fresh and could
+// be used by [Client.Do] without validation.
+//
+// Unlike [Client.Do], probeFresh does not report a hit to the [Observer] or count the use of the response for
+// refresh-ahead.
+func (c *Client) probeFresh(ctx context.Context, config *Config, req *http.Request) (int, bool) {
+	ctx, span := c.startSpan(ctx, "httpcache.Client.Warm")
+	defer span.End()
+
+	policy, _ := RequestPolicyFromContext(ctx)
+
+	req = c.partition(req)
+
+	reqDirectives := c.requestDirectives(ctx, span, req, policy)
+
+	stored := c.storeGet(ctx, span, config, req)
+	if stored == nil {
+		return 0, false
+	}
+
+	discardBody(stored)
+
+	respDirectives := c.responseDirectives(ctx, span, req, stored)
+
+	freshness, _, reload := c.storedFreshness(ctx, span, config, req, reqDirectives, respDirectives, policy, stored)
+	if reload || freshness != FreshnessFresh || requiresValidation(respDirectives) {
+		return 0, false
+	}
+
+	return stored.StatusCode, true
+}
+
+// discardBody reads and closes the body of the given response.
+func discardBody(resp *http.Response) {
+	if resp.Body == nil {
+		return
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
+
+// warmLimiter limits the rate of requests sent by [Client.Warm].
+type warmLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until the next request can be sent or ctx is canceled.
+func (l *warmLimiter) wait(ctx context.Context) error {
+	if l.interval <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	d := at.Sub(now)
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
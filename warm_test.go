@@ -0,0 +1,279 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/nussjustin/httpcache"
+)
+
+func newWarmClient(clock httpcache.Clock, requests *[]string) *httpcache.Client {
+	var mu sync.Mutex
+
+	return &httpcache.Client{
+		Config: httpcache.Config{Clock: clock},
+		Store:  &httpcache.MemoryStore{Clock: clock},
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				mu.Lock()
+				*requests = append(*requests, req.URL.Path+" "+req.Header.Get("Accept-Language"))
+				mu.Unlock()
+
+				if req.URL.Path == "/error" {
+					return nil, errors.New("failed")
+				}
+
+				status := http.StatusOK
+				if req.Header.Get("If-None-Match") != "" {
+					status = http.StatusNotModified
+				}
+
+				cacheControl := "max-age=60"
+				if req.URL.Path == "/no-store" {
+					cacheControl = "no-store"
+				}
+
+				resp := newResp(
+					withRespStatus(status),
+					withRespHeader("Cache-Control", cacheControl),
+					withRespHeader("Date", clock.Now().Format(http.TimeFormat)),
+					withRespHeader("Etag", `"1"`),
+					withRespHeader("Vary", "Accept-Language"))
+				resp.Request = req
+				return resp, nil
+			}),
+		},
+	}
+}
+
+func warmOutcomes(results []httpcache.WarmResult) []string {
+	outcomes := make([]string, len(results))
+	for i, r := range results {
+		outcomes[i] = r.Request.URL.Path + " " + r.Request.Header.Get("Accept-Language") + " " + r.Outcome.String()
+	}
+	return outcomes
+}
+
+func TestClient_Warm(t *testing.T) {
+	clock := httpcache.NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var requests []string
+
+	client := newWarmClient(clock, &requests)
+
+	reqs := []*http.Request{
+		newReq(withReqUrl("http://example.com/a")),
+		newReq(withReqUrl("http://example.com/no-store")),
+		newReq(withReqUrl("http://example.com/error")),
+	}
+
+	opts := httpcache.WarmOptions{
+		Concurrency: 2,
+		Variants:    []http.Header{{"Accept-Language": {"de"}}, {"accept-language": {"en"}}},
+	}
+
+	warm := func(want ...string) {
+		t.Helper()
+
+		requests = nil
+
+		results := client.Warm(t.Context(), reqs, opts)
+
+		if got := warmOutcomes(results); !slices.Equal(got, want) {
+			t.Errorf("got outcomes %q, want %q", got, want)
+		}
+
+		for _, r := range results {
+			if (r.Outcome == httpcache.WarmFailed) != (r.Err != nil) {
+				t.Errorf("got error %v for outcome %s", r.Err, r.Outcome)
+			}
+		}
+	}
+
+	warm(
+		"/a de stored", "/a en stored",
+		"/no-store de not-stored", "/no-store en not-stored",
+		"/error de failed", "/error en failed")
+
+	if got, want := len(requests), 6; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+
+	warm(
+		"/a de fresh", "/a en fresh",
+		"/no-store de not-stored", "/no-store en not-stored",
+		"/error de failed", "/error en failed")
+
+	if got, want := len(requests), 4; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+
+	clock.Advance(2 * time.Minute)
+
+	warm(
+		"/a de revalidated", "/a en revalidated",
+		"/no-store de not-stored", "/no-store en not-stored",
+		"/error de failed", "/error en failed")
+
+	for _, r := range reqs {
+		if got := r.Header.Get("Accept-Language"); got != "" {
+			t.Errorf("got Accept-Language %q on original request", got)
+		}
+	}
+}
+
+func TestClient_Warm_Interval(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		var requests []string
+
+		client := newWarmClient(httpcache.NewFakeClock(time.Now()), &requests)
+
+		start := time.Now()
+
+		results, err := client.WarmURLs(t.Context(), strings.NewReader(`
+# comment
+http://example.com/a
+http://example.com/b
+
+http://example.com/c
+`), httpcache.WarmOptions{Concurrency: 3, Interval: time.Second})
+		if err != nil {
+			t.Fatalf("WarmURLs() error = %v", err)
+		}
+
+		if got, want := warmOutcomes(results), []string{"/a  stored", "/b  stored", "/c  stored"}; !slices.Equal(got, want) {
+			t.Errorf("got outcomes %q, want %q", got, want)
+		}
+
+		if got, want := time.Since(start), 2*time.Second; got != want {
+			t.Errorf("took %s, want %s", got, want)
+		}
+
+		// Fresh responses are not rate limited.
+		start = time.Now()
+
+		_ = client.Warm(t.Context(), []*http.Request{results[0].Request, results[1].Request}, httpcache.WarmOptions{
+			Interval: time.Second,
+		})
+
+		if got := time.Since(start); got != 0 {
+			t.Errorf("took %s for fresh responses, want 0", got)
+		}
+	})
+}
+
+func TestClient_Warm_Canceled(t *testing.T) {
+	var requests []string
+
+	client := newWarmClient(httpcache.NewFakeClock(time.Now()), &requests)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	results := client.Warm(ctx, []*http.Request{newReq(), newReq()}, httpcache.WarmOptions{})
+
+	for _, r := range results {
+		if r.Outcome != httpcache.WarmFailed || !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("got outcome %s with error %v, want %s with %v", r.Outcome, r.Err, httpcache.WarmFailed, context.Canceled)
+		}
+	}
+
+	if len(requests) != 0 {
+		t.Errorf("got %d requests, want none", len(requests))
+	}
+}
+
+func TestClient_WarmURLs_Invalid(t *testing.T) {
+	var requests []string
+
+	client := newWarmClient(httpcache.NewFakeClock(time.Now()), &requests)
+
+	_, err := client.WarmURLs(t.Context(), strings.NewReader("http://example.com/\n\n::invalid\n"), httpcache.WarmOptions{})
+	if err == nil || !strings.HasPrefix(err.Error(), "line 3: ") {
+		t.Errorf("WarmURLs() error = %v, want error for line 3", err)
+	}
+
+	if len(requests) != 0 {
+		t.Errorf("got %d requests, want none", len(requests))
+	}
+}
+
+func TestWarmOutcome_String(t *testing.T) {
+	for o := httpcache.WarmFresh; o <= httpcache.WarmFailed; o++ {
+		if got := o.String(); got == "" || strings.ContainsAny(got, " \t\",;=") {
+			t.Errorf("WarmOutcome(%d).String() = %q, want non-empty token", o, got)
+		}
+	}
+}
+
+func TestClient_Warm_Bypass(t *testing.T) {
+	clock := httpcache.NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var requests []string
+
+	client := newWarmClient(clock, &requests)
+
+	reqs := []*http.Request{
+		newReq(withReqUrl("http://example.com/delete"), withReqMethod(http.MethodDelete)),
+		newReq(withReqUrl("http://example.com/expect"), withReqHeader("Expect", "100-continue")),
+	}
+
+	results := client.Warm(t.Context(), reqs, httpcache.WarmOptions{})
+
+	if got, want := warmOutcomes(results), []string{"/delete  not-stored", "/expect  not-stored"}; !slices.Equal(got, want) {
+		t.Errorf("got outcomes %q, want %q", got, want)
+	}
+
+	if want := []string{"/delete ", "/expect "}; !slices.Equal(requests, want) {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+}
+
+func TestClient_Warm_FreshNotCounted(t *testing.T) {
+	clock := httpcache.NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var requests []string
+
+	observer := &recordingObserver{}
+
+	client := newWarmClient(clock, &requests)
+	client.Observer = observer
+	client.RefreshAhead = httpcache.RefreshAhead{Fraction: 1, MinHits: 3}
+
+	reqs := []*http.Request{newReq(withReqUrl("http://example.com/a"))}
+
+	for _, want := range []string{"/a  stored", "/a  fresh", "/a  fresh"} {
+		results := client.Warm(t.Context(), reqs, httpcache.WarmOptions{})
+
+		if got := warmOutcomes(results); !slices.Equal(got, []string{want}) {
+			t.Errorf("got outcomes %q, want %q", got, want)
+		}
+	}
+
+	if slices.Contains(observer.types(), httpcache.EventHit) {
+		t.Errorf("got %s event for warmed fresh response", httpcache.EventHit)
+	}
+
+	// The response must only be refreshed once it was used often enough by Do.
+	resp, err := client.Do(newReq(withReqUrl("http://example.com/a")))
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got, want := len(requests), 1; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+}